@@ -0,0 +1,20 @@
+package api
+
+import (
+	"ai-agent/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReloadIntentsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := chatSvc.ReloadIntentConfig()
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "intents reloaded", "intent_version": version})
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"ai-agent/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestHandoffHandler 用户主动转人工
+func RequestHandoffHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			UserID string `json:"user_id"`
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+			return
+		}
+
+		session, err := chatSvc.RequestHandoff(c.Request.Context(), c.Param("session_id"), req.UserID, req.Reason)
+		if err != nil {
+			c.JSON(handoffErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"session_id": session.ID, "session_state": session.State, "handoff": session.Handoff})
+	}
+}
+
+func ListWaitingSessionsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := chatSvc.ListWaitingHandoffs(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions, "total": len(sessions)})
+	}
+}
+
+func ClaimSessionHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			AgentID string `json:"agent_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.AgentID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id is required"})
+			return
+		}
+
+		session, err := chatSvc.ClaimHandoff(c.Request.Context(), c.Param("session_id"), req.AgentID)
+		if err != nil {
+			c.JSON(handoffErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+func AgentReplyHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			AgentID string `json:"agent_id"`
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.AgentID == "" || req.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id and content are required"})
+			return
+		}
+
+		session, err := chatSvc.ReplyHandoff(c.Request.Context(), c.Param("session_id"), req.AgentID, req.Content)
+		if err != nil {
+			c.JSON(handoffErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+func ReleaseSessionHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			AgentID string `json:"agent_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.AgentID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id is required"})
+			return
+		}
+
+		session, err := chatSvc.ReleaseHandoff(c.Request.Context(), c.Param("session_id"), req.AgentID)
+		if err != nil {
+			c.JSON(handoffErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// handoffErrorStatus 将人工接管错误映射为 HTTP 状态码
+func handoffErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotHandedOff), errors.Is(err, service.ErrHandoffClaimed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrHandoffNotClaimed):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"ai-agent/dao"
+	"ai-agent/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryArchiveHandler 查询归档会话，支持 session_id、user_id、from、to、limit 参数
+// from/to 为 RFC3339 时间或 2006-01-02 日期，按归档时间过滤，to 不包含
+func QueryArchiveHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := dao.ArchiveFilter{
+			SessionID: c.Query("session_id"),
+			UserID:    c.Query("user_id"),
+		}
+
+		var err error
+		if filter.From, err = parseArchiveTime(c.Query("from")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		if filter.To, err = parseArchiveTime(c.Query("to")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		if v := c.Query("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+		}
+
+		records, err := chatSvc.QueryArchive(c.Request.Context(), filter)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrArchiveDisabled) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sessions": records, "total": len(records)})
+	}
+}
+
+// parseArchiveTime 解析 RFC3339 时间或日期，空字符串返回零值
+func parseArchiveTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"ai-agent/model"
 	"ai-agent/service"
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,7 +20,7 @@ func ChatHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 
 		resp, err := chatSvc.HandleMessage(c.Request.Context(), req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -26,6 +28,41 @@ func ChatHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 	}
 }
 
+// chatErrorStatus 将对话错误映射为 HTTP 状态码，会话正忙或消息仍在处理时客户端可以稍后重试
+func chatErrorStatus(err error) int {
+	if errors.Is(err, service.ErrSessionBusy) || errors.Is(err, service.ErrMessageInProgress) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ChatStreamHandler 通过 SSE 推送对话事件：decision、flow_step、delta、done、error
+func ChatStreamHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ChatRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		// 客户端断开后仍然完成本轮处理并保存会话，只是不再推送；AI 调用仍受 ai.timeout 约束
+		ctx := context.WithoutCancel(c.Request.Context())
+		_, _ = chatSvc.HandleMessageStream(ctx, req, func(event model.StreamEvent) {
+			if c.Request.Context().Err() != nil {
+				return
+			}
+			c.SSEvent(string(event.Type), event.Data)
+			c.Writer.Flush()
+		})
+	}
+}
+
 func IntentRecognitionHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req model.IntentRecognitionRequest
@@ -104,6 +141,25 @@ func SessionHistoryHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 	}
 }
 
+// SessionEventsHandler 返回会话的事件日志，用于审计和重放
+func SessionEventsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Param("session_id")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
+			return
+		}
+
+		events, err := chatSvc.GetSessionEvents(c.Request.Context(), sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, events)
+	}
+}
+
 func ClearSessionHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionID := c.Param("session_id")
@@ -120,3 +176,25 @@ func ClearSessionHandler(chatSvc *service.ChatService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "session cleared"})
 	}
 }
+
+// PushMessageHandler 向会话推送系统消息
+// 来源固定为 system，坐席和工单消息只能由 ReplyHandoff、UpdateTicketStatus 在服务端推送，调用方不能冒充
+func PushMessageHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Param("session_id")
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+			return
+		}
+
+		if err := chatSvc.PushToSession(c.Request.Context(), sessionID, service.PushSourceSystem, req.Content); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "pushed"})
+	}
+}
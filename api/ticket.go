@@ -0,0 +1,104 @@
+package api
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"ai-agent/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetTicketHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, err := chatSvc.GetTicket(c.Request.Context(), c.Param("ticket_id"))
+		if err != nil {
+			c.JSON(ticketErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+func ListTicketsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := service.TicketQuery{UserID: c.Query("user_id"), Status: model.TicketStatus(c.Query("status"))}
+		var err error
+		if v := c.Query("offset"); v != "" {
+			if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+				return
+			}
+		}
+		if v := c.Query("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+		}
+
+		resp, err := chatSvc.ListTickets(c.Request.Context(), q)
+		if err != nil {
+			c.JSON(ticketErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func UpdateTicketHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.TicketUpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status is required"})
+			return
+		}
+
+		ticket, err := chatSvc.UpdateTicketStatus(c.Request.Context(), c.Param("ticket_id"), req)
+		if err != nil {
+			c.JSON(ticketErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+func AddTicketCommentHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Author  string `json:"author"`
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+			return
+		}
+
+		ticket, err := chatSvc.AddTicketComment(c.Request.Context(), c.Param("ticket_id"), req.Author, req.Content)
+		if err != nil {
+			c.JSON(ticketErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+// ticketErrorStatus 将工单错误映射为 HTTP 状态码
+func ticketErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dao.ErrTicketNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidTicketStatus), errors.Is(err, dao.ErrInvalidParam):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidTicketTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"ai-agent/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListUserSessionsHandler 查询用户的历史会话，支持 state、offset、limit 参数
+func ListUserSessionsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := service.UserSessionQuery{State: model.SessionState(c.Query("state"))}
+		var err error
+		if v := c.Query("offset"); v != "" {
+			if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+				return
+			}
+		}
+		if v := c.Query("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+		}
+
+		resp, err := chatSvc.ListUserSessions(c.Request.Context(), c.Param("user_id"), q)
+		if err != nil {
+			c.JSON(userSessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// DeleteUserSessionsHandler 删除用户的全部会话
+func DeleteUserSessionsHandler(chatSvc *service.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("user_id")
+		deleted, err := chatSvc.DeleteUserSessions(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(userSessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "deleted": deleted})
+	}
+}
+
+// userSessionErrorStatus 将用户会话接口的错误映射为 HTTP 状态码
+func userSessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidSessionState), errors.Is(err, dao.ErrInvalidParam):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"ai-agent/model"
+	"ai-agent/service"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// newWSUpgrader 按 allowedOrigins 校验浏览器的 Origin：
+// 同源和不带 Origin 的连接（非浏览器客户端）总是允许，"*" 表示允许任意来源
+func newWSUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return &websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || allowed["*"] || allowed[strings.ToLower(origin)] {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		},
+	}
+}
+
+// WebSocketHandler 将连接绑定到 session_id：
+// 客户端发送 ChatRequest，服务端返回 ChatResponse；推送到该会话的消息也以 ChatResponse 帧下发
+// 浏览器连接的 Origin 必须同源或在 allowedOrigins 中
+func WebSocketHandler(chatSvc *service.ChatService, allowedOrigins []string) gin.HandlerFunc {
+	wsUpgrader := newWSUpgrader(allowedOrigins)
+	return func(c *gin.Context) {
+		sessionID := c.Query("session_id")
+		if sessionID == "" {
+			sessionID = uuid.New().String()
+		}
+
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Printf("[WS] 升级连接失败: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		// 连接不受 HTTP 请求上下文约束，由读循环结束时取消
+		ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+		defer cancel()
+
+		pushes, unsubscribe, err := chatSvc.SubscribeSession(ctx, sessionID)
+		if err != nil {
+			log.Printf("[WS %s] 订阅推送失败: %v", sessionID, err)
+			conn.WriteJSON(gin.H{"error": err.Error()})
+			return
+		}
+		defer unsubscribe()
+
+		var writeMu sync.Mutex
+		write := func(v interface{}) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			return conn.WriteJSON(v)
+		}
+
+		log.Printf("[WS %s] 连接建立", sessionID)
+
+		// 推送消息和心跳
+		go func() {
+			ticker := time.NewTicker(wsPingPeriod)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-pushes:
+					if !ok {
+						// 推送总线已关闭（服务退出），关闭连接结束读循环
+						conn.Close()
+						return
+					}
+					if err := write(model.ChatResponse{
+						Reply:     msg.Content,
+						Type:      model.IntentUnknown,
+						SessionID: msg.SessionID,
+						Source:    msg.Source,
+					}); err != nil {
+						conn.Close()
+						return
+					}
+				case <-ticker.C:
+					writeMu.Lock()
+					err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+					writeMu.Unlock()
+					if err != nil {
+						conn.Close()
+						return
+					}
+				}
+			}
+		}()
+
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		for {
+			var req model.ChatRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+					log.Printf("[WS %s] 读取失败: %v", sessionID, err)
+				}
+				break
+			}
+			conn.SetReadDeadline(time.Now().Add(wsPongWait))
+			req.SessionID = sessionID
+
+			resp, err := chatSvc.HandleMessage(ctx, req)
+			if err != nil {
+				err = write(gin.H{"error": err.Error(), "session_id": sessionID})
+			} else {
+				err = write(resp)
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		log.Printf("[WS %s] 连接关闭", sessionID)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"ai-agent/config"
+	"ai-agent/service"
+	"fmt"
+	"os"
+)
+
+// 校验服务配置、意图配置和 Flow 定义，参数与主程序相同：go run ./cmd/validate -config config/config.yaml
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	intentConfig, err := service.LoadIntentConfig(cfg.Intents.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载意图配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	flowDefs, err := service.LoadFlowDefinitions(cfg.Intents.FlowsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载Flow配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := service.Flows.RegisterDefinitions(flowDefs); err != nil {
+		fmt.Fprintf(os.Stderr, "注册Flow失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := service.ValidateConfig(intentConfig, service.Flows); err != nil {
+		fmt.Fprintf(os.Stderr, "配置校验失败:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("配置校验通过：%d 个意图，%d 个Flow\n", len(intentConfig.Intents), len(service.Flows))
+}
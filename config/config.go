@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 服务配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	AI      AIConfig      `yaml:"ai"`
+	Session SessionConfig `yaml:"session"`
+	Redis   RedisConfig   `yaml:"redis"`
+	Intents IntentsConfig `yaml:"intents"`
+	Flows   FlowsConfig   `yaml:"flows"`
+	Archive ArchiveConfig `yaml:"archive"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+	// DrainDelay 收到退出信号后先标记为未就绪，等待负载均衡摘除流量的时间
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// ShutdownTimeout 等待进行中请求完成的最长时间
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// AllowedOrigins WebSocket 允许的浏览器来源，如 https://app.example.com；同源总是允许，"*" 允许任意来源
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type AIConfig struct {
+	Backend string        `yaml:"backend"` // http | fake
+	BaseURL string        `yaml:"base_url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type SessionConfig struct {
+	Store       string        `yaml:"store"` // redis | memory
+	TTL         time.Duration `yaml:"ttl"`
+	SaveRetries int           `yaml:"save_retries"` // 乐观锁保存重试次数，ChatService 和会话存储共用
+	// SummaryThreshold 未摘要的消息超过该数量时刷新会话摘要，0 表示不做摘要
+	SummaryThreshold int `yaml:"summary_threshold"`
+	// SummaryKeep 刷新摘要时保留原文的最近消息数
+	SummaryKeep int `yaml:"summary_keep"`
+	// LockTTL 会话锁租约时长，也是一轮对话的最长处理时间，0 表示不加锁
+	LockTTL time.Duration `yaml:"lock_ttl"`
+	// LockWait 会话被占用或同一消息 ID 正在处理时排队等待的最长时间，0 表示直接拒绝
+	LockWait time.Duration `yaml:"lock_wait"`
+}
+
+type RedisConfig struct {
+	Addr      string `yaml:"addr"`
+	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+type IntentsConfig struct {
+	Path           string        `yaml:"path"`
+	FlowsDir       string        `yaml:"flows_dir"`
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 轮询 intents.yaml 的间隔，0 表示只能通过管理接口热加载
+}
+
+// ArchiveConfig 会话归档配置
+type ArchiveConfig struct {
+	Backend string `yaml:"backend"` // jsonl | sqlite | none
+	Path    string `yaml:"path"`    // jsonl 为目录，sqlite 为数据库文件
+	// SweepInterval 后台扫描即将过期会话的间隔
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+}
+
+// FlowsConfig Flow 运行策略，overrides 按 FlowID 覆盖默认值
+type FlowsConfig struct {
+	FlowPolicy `yaml:",inline"`
+	Overrides  map[string]FlowPolicy `yaml:"overrides"`
+}
+
+// FlowPolicy 单个 Flow 的空闲超时策略，零值字段沿用默认值
+type FlowPolicy struct {
+	// IdleTimeout 距上次对话超过该时间视为空闲，0 表示不超时
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// OnIdle 空闲后的处理方式：ask 询问继续还是新问题，reset 结束旧流程，resume 直接继续
+	OnIdle string `yaml:"on_idle"`
+}
+
+// Policy 返回指定 Flow 生效的策略
+func (c FlowsConfig) Policy(flowID string) FlowPolicy {
+	p := c.FlowPolicy
+	if o, ok := c.Overrides[flowID]; ok {
+		if o.IdleTimeout != 0 {
+			p.IdleTimeout = o.IdleTimeout
+		}
+		if o.OnIdle != "" {
+			p.OnIdle = o.OnIdle
+		}
+	}
+	return p
+}
+
+// ReplyPendingTimeout 消息处理中标记的有效期，即一轮对话的最长处理时间
+// 加锁时为 session.lock_ttl，不加锁时按意图识别和生成回复两次 AI 调用估算
+func (c *Config) ReplyPendingTimeout() time.Duration {
+	if c.Session.LockTTL > 0 {
+		return c.Session.LockTTL
+	}
+	return 2 * c.AI.Timeout
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		AI: AIConfig{
+			Backend: "http",
+			BaseURL: "http://127.0.0.1:8000",
+			Timeout: 30 * time.Second,
+		},
+		Session: SessionConfig{
+			Store:            "redis",
+			TTL:              24 * time.Hour,
+			SaveRetries:      3,
+			SummaryThreshold: 30,
+			SummaryKeep:      10,
+			LockWait:         5 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr:      "localhost:6379",
+			KeyPrefix: "ai-agent:",
+		},
+		Intents: IntentsConfig{
+			Path:           "config/intents.yaml",
+			FlowsDir:       "config/flows",
+			ReloadInterval: 5 * time.Second,
+		},
+		Flows: FlowsConfig{
+			FlowPolicy: FlowPolicy{
+				IdleTimeout: 30 * time.Minute,
+				OnIdle:      "ask",
+			},
+		},
+		Archive: ArchiveConfig{
+			Backend:       "jsonl",
+			Path:          "data/archive",
+			SweepInterval: 10 * time.Minute,
+		},
+	}
+}
+
+// override 一个可以被环境变量和命令行参数覆盖的配置项
+type override struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func overrides() []override {
+	return []override{
+		{"SERVER_ADDR", "addr", "HTTP 监听地址", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+		{"SERVER_DRAIN_DELAY", "drain-delay", "退出前保持未就绪的时间", durationSetter(func(c *Config) *time.Duration { return &c.Server.DrainDelay })},
+		{"SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "等待进行中请求完成的最长时间", durationSetter(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+		{"SERVER_ALLOWED_ORIGINS", "allowed-origins", "WebSocket 允许的浏览器来源，逗号分隔", func(c *Config, v string) error { c.Server.AllowedOrigins = splitList(v); return nil }},
+		{"AI_BACKEND", "ai-backend", "AI 后端：http | fake", func(c *Config, v string) error { c.AI.Backend = v; return nil }},
+		{"AI_BASE_URL", "ai-base-url", "Python AI 服务地址", func(c *Config, v string) error { c.AI.BaseURL = v; return nil }},
+		{"AI_TIMEOUT", "ai-timeout", "AI 服务请求超时", durationSetter(func(c *Config) *time.Duration { return &c.AI.Timeout })},
+		{"SESSION_STORE", "session-store", "会话存储：redis | memory", func(c *Config, v string) error { c.Session.Store = v; return nil }},
+		{"SESSION_TTL", "session-ttl", "会话过期时间", durationSetter(func(c *Config) *time.Duration { return &c.Session.TTL })},
+		{"SESSION_SAVE_RETRIES", "session-save-retries", "乐观锁保存重试次数", intSetter(func(c *Config) *int { return &c.Session.SaveRetries })},
+		{"SESSION_SUMMARY_THRESHOLD", "session-summary-threshold", "未摘要消息超过该数量时刷新摘要，0 表示关闭", intSetter(func(c *Config) *int { return &c.Session.SummaryThreshold })},
+		{"SESSION_SUMMARY_KEEP", "session-summary-keep", "刷新摘要时保留原文的最近消息数", intSetter(func(c *Config) *int { return &c.Session.SummaryKeep })},
+		{"SESSION_LOCK_TTL", "session-lock-ttl", "会话锁租约时长，0 表示不加锁", durationSetter(func(c *Config) *time.Duration { return &c.Session.LockTTL })},
+		{"SESSION_LOCK_WAIT", "session-lock-wait", "会话被占用时排队等待的最长时间，0 表示直接拒绝", durationSetter(func(c *Config) *time.Duration { return &c.Session.LockWait })},
+		{"REDIS_ADDR", "redis-addr", "Redis 地址", func(c *Config, v string) error { c.Redis.Addr = v; return nil }},
+		{"REDIS_PASSWORD", "redis-password", "Redis 密码", func(c *Config, v string) error { c.Redis.Password = v; return nil }},
+		{"REDIS_DB", "redis-db", "Redis DB", intSetter(func(c *Config) *int { return &c.Redis.DB })},
+		{"REDIS_KEY_PREFIX", "redis-key-prefix", "Redis key 前缀", func(c *Config, v string) error { c.Redis.KeyPrefix = v; return nil }},
+		{"INTENTS_PATH", "intents", "意图配置文件路径", func(c *Config, v string) error { c.Intents.Path = v; return nil }},
+		{"FLOWS_DIR", "flows", "声明式 Flow 目录", func(c *Config, v string) error { c.Intents.FlowsDir = v; return nil }},
+		{"INTENTS_RELOAD_INTERVAL", "intents-reload-interval", "意图配置轮询间隔", durationSetter(func(c *Config) *time.Duration { return &c.Intents.ReloadInterval })},
+		{"FLOWS_IDLE_TIMEOUT", "flows-idle-timeout", "Flow 默认空闲超时", durationSetter(func(c *Config) *time.Duration { return &c.Flows.IdleTimeout })},
+		{"FLOWS_ON_IDLE", "flows-on-idle", "Flow 空闲后的处理方式：ask | reset | resume", func(c *Config, v string) error { c.Flows.OnIdle = v; return nil }},
+		{"ARCHIVE_BACKEND", "archive-backend", "会话归档：jsonl | sqlite | none", func(c *Config, v string) error { c.Archive.Backend = v; return nil }},
+		{"ARCHIVE_PATH", "archive-path", "归档目录（jsonl）或数据库文件（sqlite）", func(c *Config, v string) error { c.Archive.Path = v; return nil }},
+		{"ARCHIVE_SWEEP_INTERVAL", "archive-sweep-interval", "扫描即将过期会话的间隔", durationSetter(func(c *Config) *time.Duration { return &c.Archive.SweepInterval })},
+	}
+}
+
+func durationSetter(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// splitList 解析逗号分隔的列表，忽略空白项
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func intSetter(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// Load 解析命令行参数，依次应用配置文件、环境变量和命令行参数
+// 使用默认路径时配置文件可以不存在
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ai-agent", flag.ContinueOnError)
+	path := fs.String("config", "config/config.yaml", "配置文件路径")
+
+	items := overrides()
+	flagValues := make(map[string]*string, len(items))
+	for _, o := range items {
+		flagValues[o.flag] = fs.String(o.flag, "", fmt.Sprintf("%s（环境变量 %s）", o.usage, o.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	cfg := Default()
+
+	data, err := os.ReadFile(*path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit["config"]:
+	default:
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	for _, o := range items {
+		if v, ok := os.LookupEnv(o.env); ok {
+			if err := o.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("环境变量 %s 无效: %w", o.env, err)
+			}
+		}
+	}
+	for _, o := range items {
+		if explicit[o.flag] {
+			if err := o.set(cfg, *flagValues[o.flag]); err != nil {
+				return nil, fmt.Errorf("参数 -%s 无效: %w", o.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验配置取值
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr 不能为空"))
+	}
+	switch c.AI.Backend {
+	case "http":
+		if c.AI.BaseURL == "" {
+			errs = append(errs, errors.New("ai.base_url 不能为空"))
+		}
+	case "fake":
+	default:
+		errs = append(errs, fmt.Errorf("ai.backend 不支持 %q（仅支持 http/fake）", c.AI.Backend))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay 不能为负数"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout 必须大于 0"))
+	}
+	if c.AI.Timeout <= 0 {
+		errs = append(errs, errors.New("ai.timeout 必须大于 0"))
+	}
+	switch c.Session.Store {
+	case "redis":
+		if c.Redis.Addr == "" {
+			errs = append(errs, errors.New("redis.addr 不能为空"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("session.store 不支持 %q（仅支持 redis/memory）", c.Session.Store))
+	}
+	if c.Session.TTL <= 0 {
+		errs = append(errs, errors.New("session.ttl 必须大于 0"))
+	}
+	if c.Session.SaveRetries < 0 {
+		errs = append(errs, errors.New("session.save_retries 不能为负数"))
+	}
+	if c.Session.SummaryThreshold < 0 {
+		errs = append(errs, errors.New("session.summary_threshold 不能为负数"))
+	}
+	if c.Session.SummaryThreshold > 0 && (c.Session.SummaryKeep < 0 || c.Session.SummaryKeep >= c.Session.SummaryThreshold) {
+		errs = append(errs, errors.New("session.summary_keep 必须在 0 到 summary_threshold 之间"))
+	}
+	if c.Session.LockTTL < 0 || c.Session.LockWait < 0 {
+		errs = append(errs, errors.New("session.lock_ttl 和 session.lock_wait 不能为负数"))
+	}
+	if c.Session.LockTTL > 0 && c.Session.LockTTL <= c.AI.Timeout {
+		errs = append(errs, errors.New("session.lock_ttl 必须大于 ai.timeout，否则调用 AI 期间租约就会过期"))
+	}
+	if c.Intents.Path == "" {
+		errs = append(errs, errors.New("intents.path 不能为空"))
+	}
+	switch c.Archive.Backend {
+	case "jsonl", "sqlite":
+		if c.Archive.Path == "" {
+			errs = append(errs, errors.New("archive.path 不能为空"))
+		}
+		if c.Archive.SweepInterval <= 0 {
+			errs = append(errs, errors.New("archive.sweep_interval 必须大于 0"))
+		}
+	case "none":
+	default:
+		errs = append(errs, fmt.Errorf("archive.backend 不支持 %q（仅支持 jsonl/sqlite/none）", c.Archive.Backend))
+	}
+	errs = append(errs, c.Flows.FlowPolicy.validate("flows"))
+	for flowID, p := range c.Flows.Overrides {
+		errs = append(errs, p.validate("flows.overrides."+flowID))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p FlowPolicy) validate(prefix string) error {
+	var errs []error
+	if p.IdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.idle_timeout 不能为负数", prefix))
+	}
+	switch p.OnIdle {
+	case "", "ask", "reset", "resume":
+	default:
+		errs = append(errs, fmt.Errorf("%s.on_idle 不支持 %q（仅支持 ask/reset/resume）", prefix, p.OnIdle))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,204 @@
+package dao
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent/model"
+)
+
+// ArchiveFilter 归档查询条件，空字段表示不过滤，时间范围按归档时间计算
+type ArchiveFilter struct {
+	SessionID string
+	UserID    string
+	From      time.Time // 包含
+	To        time.Time // 不包含
+	Limit     int
+}
+
+// Archive 只追加的会话归档，会话过期后仍可用于质检和纠纷处理
+type Archive interface {
+	Append(ctx context.Context, record model.ArchivedSession) error
+	// Query 按归档时间倒序返回记录
+	Query(ctx context.Context, filter ArchiveFilter) ([]model.ArchivedSession, error)
+	Close() error
+}
+
+var (
+	_ Archive = (*JSONLArchive)(nil)
+	_ Archive = (*SQLiteArchive)(nil)
+)
+
+// jsonlDateLayout 归档文件按天切分：sessions-2006-01-02.jsonl
+const jsonlDateLayout = "2006-01-02"
+
+// JSONLArchive 以 JSON Lines 文件归档，每天一个文件，只追加不修改
+// mu 只串行化写入，查询不加锁，读到正在写入的半行时跳过
+type JSONLArchive struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewJSONLArchive 创建 JSONL 归档，目录不存在时自动创建
+func NewJSONLArchive(dir string) (*JSONLArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &JSONLArchive{dir: dir}, nil
+}
+
+func (a *JSONLArchive) Append(ctx context.Context, record model.ArchivedSession) error {
+	archivedAt, err := time.Parse(time.RFC3339Nano, record.ArchivedAt)
+	if err != nil {
+		return fmt.Errorf("%w: archived_at %q", ErrInvalidParam, record.ArchivedAt)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.fileFor(archivedAt), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	// 上次写入中断留下没有换行的半行时先补换行，避免新记录接在半行后面一起损坏
+	line := append(data, '\n')
+	if torn, err := endsWithoutNewline(f); err != nil {
+		f.Close()
+		return err
+	} else if torn {
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (a *JSONLArchive) Query(ctx context.Context, filter ArchiveFilter) ([]model.ArchivedSession, error) {
+	files, err := filepath.Glob(filepath.Join(a.dir, "sessions-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []model.ArchivedSession
+	for _, file := range files {
+		if !a.fileInRange(file, filter) {
+			continue
+		}
+		matched, err := scanJSONL(file, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, matched...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339Nano, records[i].ArchivedAt)
+		tj, _ := time.Parse(time.RFC3339Nano, records[j].ArchivedAt)
+		return ti.After(tj)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+func (a *JSONLArchive) Close() error {
+	return nil
+}
+
+func (a *JSONLArchive) fileFor(t time.Time) string {
+	return filepath.Join(a.dir, "sessions-"+t.UTC().Format(jsonlDateLayout)+".jsonl")
+}
+
+// fileInRange 根据文件名中的日期跳过时间范围之外的文件
+func (a *JSONLArchive) fileInRange(file string, filter ArchiveFilter) bool {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "sessions-"), ".jsonl")
+	day, err := time.Parse(jsonlDateLayout, name)
+	if err != nil {
+		return false
+	}
+	if !filter.From.IsZero() && !day.Add(24*time.Hour).After(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !day.Before(filter.To) {
+		return false
+	}
+	return true
+}
+
+// endsWithoutNewline 判断非空文件的最后一个字节是否不是换行
+func endsWithoutNewline(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// scanJSONL 逐行读取归档文件，返回符合条件的记录
+// 无法解析的行（写入中断或正在写入的半行）记录日志后跳过
+func scanJSONL(file string, filter ArchiveFilter) ([]model.ArchivedSession, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []model.ArchivedSession
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record model.ArchivedSession
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("[Archive] %s 第 %d 行无法解析，已跳过: %v", file, line, err)
+			continue
+		}
+		if matchArchive(record, filter) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func matchArchive(record model.ArchivedSession, filter ArchiveFilter) bool {
+	if filter.SessionID != "" && record.SessionID != filter.SessionID {
+		return false
+	}
+	if filter.UserID != "" && record.UserID != filter.UserID {
+		return false
+	}
+	archivedAt, err := time.Parse(time.RFC3339Nano, record.ArchivedAt)
+	if err != nil {
+		return false
+	}
+	if !filter.From.IsZero() && archivedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !archivedAt.Before(filter.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"ai-agent/model"
+)
+
+func TestJSONLArchiveTornLine(t *testing.T) {
+	ctx := context.Background()
+	archive, err := NewJSONLArchive(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	record := func(id string, sec int) model.ArchivedSession {
+		return model.ArchivedSession{SessionID: id, UserID: "u", ArchivedAt: at.Add(time.Duration(sec) * time.Second).Format(time.RFC3339Nano)}
+	}
+
+	if err := archive.Append(ctx, record("s1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写入中断：文件末尾留下没有换行的半行
+	f, err := os.OpenFile(archive.fileFor(at), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"session_id":"s2","user`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	records, err := archive.Query(ctx, ArchiveFilter{})
+	if err != nil {
+		t.Fatalf("Query with a torn line: %v", err)
+	}
+	if len(records) != 1 || records[0].SessionID != "s1" {
+		t.Fatalf("records = %+v, want only s1", records)
+	}
+
+	// 之后追加的记录不能和半行粘在一起
+	if err := archive.Append(ctx, record("s3", 3)); err != nil {
+		t.Fatal(err)
+	}
+	records, err = archive.Query(ctx, ArchiveFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].SessionID != "s3" || records[1].SessionID != "s1" {
+		t.Fatalf("records = %+v, want s3, s1", records)
+	}
+}
@@ -0,0 +1,242 @@
+package dao
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"ai-agent/model"
+)
+
+const (
+	// maxSessionMessages 会话文档最多保留的消息数
+	maxSessionMessages = 100
+	// snapshotInterval 每追加这么多事件写一次快照，并删除两个间隔之前的事件，事件流最多保留约三个间隔
+	snapshotInterval = 200
+)
+
+// diffSession 比较 session 读取时的版本 base 和修改后的 next，生成描述这次修改的事件
+// 事件序号由调用方在追加时分配
+func diffSession(base, next model.Session) []model.SessionEvent {
+	at := time.Now().Format(time.RFC3339Nano)
+	var events []model.SessionEvent
+
+	for _, msg := range appendedMessages(base.Messages, next.Messages) {
+		msg := msg
+		events = append(events, model.SessionEvent{Type: model.EventMessageAdded, At: at, Message: &msg})
+	}
+
+	switch {
+	case next.FlowID != base.FlowID && next.FlowID == "":
+		events = append(events, model.SessionEvent{Type: model.EventFlowEnded, At: at})
+	case next.FlowID != base.FlowID:
+		events = append(events, model.SessionEvent{
+			Type: model.EventFlowStarted, At: at,
+			FlowID: next.FlowID, Step: next.CurrentStep, FlowState: next.FlowState,
+		})
+	case next.CurrentStep != base.CurrentStep || !flowStateEqual(next.FlowState, base.FlowState):
+		events = append(events, model.SessionEvent{
+			Type: model.EventStepAdvanced, At: at,
+			Step: next.CurrentStep, FlowState: next.FlowState,
+		})
+	}
+
+	if next.State != base.State {
+		events = append(events, model.SessionEvent{Type: model.EventStateChanged, At: at, State: next.State})
+	}
+
+	if changed, partial := contextChanges(sessionContext(base), sessionContext(next)); len(changed) > 0 {
+		events = append(events, model.SessionEvent{Type: model.EventContextUpdated, At: at, Context: &partial, Changed: changed})
+	}
+	return events
+}
+
+// contextChanges 返回 next 相对 base 变化的字段名，以及只包含这些字段的 SessionContext
+func contextChanges(base, next model.SessionContext) ([]string, model.SessionContext) {
+	var changed []string
+	var partial model.SessionContext
+	bv, nv, pv := reflect.ValueOf(base), reflect.ValueOf(next), reflect.ValueOf(&partial).Elem()
+	for i := 0; i < nv.NumField(); i++ {
+		if jsonEqual(bv.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		changed = append(changed, contextFieldName(i))
+		pv.Field(i).Set(nv.Field(i))
+	}
+	return changed, partial
+}
+
+// applyContext 把 partial 中 changed 列出的字段写入 session
+// changed 为空时是完整的 SessionContext（修改前写入的事件）
+func applyContext(session *model.Session, changed []string, partial model.SessionContext) {
+	if len(changed) == 0 {
+		setSessionContext(session, partial)
+		return
+	}
+
+	current := sessionContext(*session)
+	cv, pv := reflect.ValueOf(&current).Elem(), reflect.ValueOf(partial)
+	for i := 0; i < cv.NumField(); i++ {
+		if slices.Contains(changed, contextFieldName(i)) {
+			cv.Field(i).Set(pv.Field(i))
+		}
+	}
+	setSessionContext(session, current)
+}
+
+// contextFieldName SessionContext 第 i 个字段的 JSON 名称
+func contextFieldName(i int) string {
+	tag := reflect.TypeOf(model.SessionContext{}).Field(i).Tag.Get("json")
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// appendedMessages 返回 next 中排在 base 最后一条消息之后的消息
+// 会话文档只保留最近的消息，next 的开头可能已被截断，因此按 base 的最后一条定位
+func appendedMessages(base, next []model.Message) []model.Message {
+	if len(base) == 0 {
+		return next
+	}
+	last := base[len(base)-1]
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i] == last {
+			return next[i+1:]
+		}
+	}
+	log.Printf("[Store] 未找到已保存的最后一条消息，按全部消息追加")
+	return next
+}
+
+// applyEvent 将事件应用到会话文档
+func applyEvent(session *model.Session, event model.SessionEvent) {
+	switch event.Type {
+	case model.EventMessageAdded:
+		if event.Message != nil {
+			session.Messages = append(session.Messages, *event.Message)
+			if len(session.Messages) > maxSessionMessages {
+				session.Messages = session.Messages[len(session.Messages)-maxSessionMessages:]
+			}
+		}
+	case model.EventFlowStarted:
+		session.FlowID = event.FlowID
+		session.CurrentStep = event.Step
+		session.FlowState = event.FlowState
+	case model.EventFlowEnded:
+		session.FlowID = ""
+		session.CurrentStep = ""
+		session.FlowState = nil
+	case model.EventStepAdvanced:
+		session.CurrentStep = event.Step
+		session.FlowState = event.FlowState
+	case model.EventStateChanged:
+		session.State = event.State
+	case model.EventContextUpdated:
+		if event.Context != nil {
+			applyContext(session, event.Changed, *event.Context)
+		}
+	case model.EventSnapshot:
+		if event.Snapshot != nil {
+			*session = *event.Snapshot
+		}
+	}
+	session.Version = event.Seq
+}
+
+// replayEvents 从最近的快照（没有快照时从第一个事件）开始依次应用事件
+// 更早的事件已被删除且没有可用的快照时无法回放，返回 false
+func replayEvents(sessionID string, events []model.SessionEvent) (model.Session, bool) {
+	start := 0
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == model.EventSnapshot {
+			start = i
+			break
+		}
+	}
+	if len(events) == 0 || (events[start].Type != model.EventSnapshot && events[start].Seq != 1) {
+		return model.Session{}, false
+	}
+
+	session := model.Session{ID: sessionID, Messages: []model.Message{}}
+	for _, event := range events[start:] {
+		applyEvent(&session, event)
+	}
+	return session, true
+}
+
+// appendEvents 为事件分配序号并应用到 current，返回新的会话文档
+func appendEvents(current model.Session, events []model.SessionEvent) model.Session {
+	for i := range events {
+		events[i].Seq = current.Version + 1
+		applyEvent(&current, events[i])
+	}
+	return current
+}
+
+// snapshotEvent 序号每跨过一个 snapshotInterval 生成一次快照，返回快照事件和可以删除的事件的序号上界
+// 保留的事件中总有上一个快照，版本稍旧的并发写入仍能回放到读取时的版本
+func snapshotEvent(prevVersion int64, next model.Session) (*model.SessionEvent, int64) {
+	if next.Version/snapshotInterval == prevVersion/snapshotInterval {
+		return nil, 0
+	}
+
+	snapshot := next
+	snapshot.Version = next.Version + 1
+	snapshot.Fence = 0
+	event := &model.SessionEvent{
+		Seq:      snapshot.Version,
+		Type:     model.EventSnapshot,
+		At:       time.Now().Format(time.RFC3339Nano),
+		Snapshot: &snapshot,
+	}
+	return event, max(snapshot.Version-2*snapshotInterval, 0)
+}
+
+func sessionContext(session model.Session) model.SessionContext {
+	return model.SessionContext{
+		UserID:         session.UserID,
+		Clarification:  session.Clarification,
+		SlotRetry:      session.SlotRetry,
+		Handoff:        session.Handoff,
+		StepHistory:    session.StepHistory,
+		IdleResume:     session.IdleResume,
+		SuspendedFlows: session.SuspendedFlows,
+		ResumeOffered:  session.ResumeOffered,
+		Summary:        session.Summary,
+		ArchivedAt:     session.ArchivedAt,
+		CreatedAt:      session.CreatedAt,
+		UpdatedAt:      session.UpdatedAt,
+	}
+}
+
+func setSessionContext(session *model.Session, c model.SessionContext) {
+	session.UserID = c.UserID
+	session.Clarification = c.Clarification
+	session.SlotRetry = c.SlotRetry
+	session.Handoff = c.Handoff
+	session.StepHistory = c.StepHistory
+	session.IdleResume = c.IdleResume
+	session.SuspendedFlows = c.SuspendedFlows
+	session.ResumeOffered = c.ResumeOffered
+	session.Summary = c.Summary
+	session.ArchivedAt = c.ArchivedAt
+	session.CreatedAt = c.CreatedAt
+	session.UpdatedAt = c.UpdatedAt
+}
+
+// flowStateEqual 比较 FlowState，nil 和空 map 视为相同
+func flowStateEqual(a, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return jsonEqual(a, b)
+}
+
+// jsonEqual 按 JSON 编码比较，FlowState 经过序列化后数字类型会变化，不能直接用 reflect.DeepEqual
+func jsonEqual(a, b interface{}) bool {
+	da, err1 := json.Marshal(a)
+	db, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(da) == string(db)
+}
@@ -0,0 +1,378 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"ai-agent/model"
+)
+
+func msg(content string) model.Message {
+	return model.Message{Role: model.RoleUser, Content: content}
+}
+
+func eventTypes(events []model.SessionEvent) []model.SessionEventType {
+	types := make([]model.SessionEventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+// assertReplayMatches 事件流回放的结果必须与保存的会话文档一致
+func assertReplayMatches(t *testing.T, store *MemoryStore, sessionID string) *model.Session {
+	t.Helper()
+	ctx := context.Background()
+
+	doc, err := store.Get(ctx, sessionID)
+	if err != nil || doc == nil {
+		t.Fatalf("Get(%s) = %v, %v", sessionID, doc, err)
+	}
+	events, err := store.Events(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Events(%s): %v", sessionID, err)
+	}
+	replayed, ok := replayEvents(sessionID, events)
+	if !ok {
+		t.Fatalf("replayEvents(%s) failed, first event %+v", sessionID, events[0])
+	}
+	if !jsonEqual(replayed, *doc) {
+		want, _ := json.Marshal(doc)
+		got, _ := json.Marshal(replayed)
+		t.Fatalf("replay mismatch\n doc:    %s\n replay: %s", want, got)
+	}
+	return doc
+}
+
+func TestDiffSession(t *testing.T) {
+	base := model.Session{
+		ID:          "s",
+		UserID:      "u",
+		State:       model.SessionOnFlow,
+		Messages:    []model.Message{msg("a")},
+		FlowID:      "return_goods",
+		CurrentStep: "ask_order_id",
+		StepHistory: []model.FlowStepSnapshot{{Step: "start"}, {Step: "ask_order_id"}},
+		UpdatedAt:   "t1",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(s *model.Session)
+		want    []model.SessionEventType
+		changed []string
+	}{
+		{
+			name:   "no change",
+			modify: func(s *model.Session) {},
+		},
+		{
+			name:   "nil and empty flow state are equal",
+			modify: func(s *model.Session) { s.FlowState = map[string]interface{}{} },
+		},
+		{
+			name: "messages appended",
+			modify: func(s *model.Session) {
+				s.Messages = append(s.Messages, msg("b"), msg("c"))
+			},
+			want: []model.SessionEventType{model.EventMessageAdded, model.EventMessageAdded},
+		},
+		{
+			name: "step advanced",
+			modify: func(s *model.Session) {
+				s.CurrentStep = "ask_reason"
+				s.FlowState = map[string]interface{}{"order_id": "123456"}
+			},
+			want: []model.SessionEventType{model.EventStepAdvanced},
+		},
+		{
+			name: "flow ended and state changed",
+			modify: func(s *model.Session) {
+				s.FlowID, s.CurrentStep, s.State = "", "", model.SessionComplete
+			},
+			want: []model.SessionEventType{model.EventFlowEnded, model.EventStateChanged},
+		},
+		{
+			name:   "flow switched",
+			modify: func(s *model.Session) { s.FlowID, s.CurrentStep = "exchange", "start" },
+			want:   []model.SessionEventType{model.EventFlowStarted},
+		},
+		{
+			name:    "only updated_at changed",
+			modify:  func(s *model.Session) { s.UpdatedAt = "t2" },
+			want:    []model.SessionEventType{model.EventContextUpdated},
+			changed: []string{"updated_at"},
+		},
+		{
+			name: "field cleared",
+			modify: func(s *model.Session) {
+				s.StepHistory = nil
+				s.UpdatedAt = "t2"
+			},
+			want:    []model.SessionEventType{model.EventContextUpdated},
+			changed: []string{"step_history", "updated_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base
+			next.Messages = append([]model.Message(nil), base.Messages...)
+			tt.modify(&next)
+
+			events := diffSession(base, next)
+			if got := eventTypes(events); !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+				t.Fatalf("event types = %v, want %v", got, tt.want)
+			}
+			for _, e := range events {
+				if e.Type != model.EventContextUpdated {
+					continue
+				}
+				if !reflect.DeepEqual(e.Changed, tt.changed) {
+					t.Fatalf("changed = %v, want %v", e.Changed, tt.changed)
+				}
+				// 未变化的字段不能出现在事件里
+				if !slices.Contains(tt.changed, "step_history") && e.Context.StepHistory != nil {
+					t.Fatalf("unchanged step_history carried in event: %+v", e.Context)
+				}
+			}
+
+			// 应用事件后必须得到修改后的会话
+			got := appendEvents(base, events)
+			got.Version = 0
+			if !jsonEqual(got, next) {
+				t.Fatalf("appendEvents = %+v, want %+v", got, next)
+			}
+		})
+	}
+}
+
+func TestApplyLegacyContextEvent(t *testing.T) {
+	// 修改前写入的 context_updated 事件没有 changed，携带完整的 SessionContext
+	session := model.Session{ID: "s", UserID: "u", ResumeOffered: true}
+	applyEvent(&session, model.SessionEvent{
+		Seq:     3,
+		Type:    model.EventContextUpdated,
+		Context: &model.SessionContext{UserID: "u", UpdatedAt: "t"},
+	})
+	if session.ResumeOffered || session.UpdatedAt != "t" || session.Version != 3 {
+		t.Fatalf("legacy context event applied as %+v", session)
+	}
+}
+
+func TestAppendEventsTruncatesMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing int
+		appended int
+		wantLen  int
+		wantLast string
+	}{
+		{"below limit", 10, 5, 15, "new-4"},
+		{"reaches limit", 95, 5, maxSessionMessages, "new-4"},
+		{"over limit", maxSessionMessages, 3, maxSessionMessages, "new-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := model.Session{ID: "s", Messages: []model.Message{}}
+			for i := 0; i < tt.existing; i++ {
+				base.Messages = append(base.Messages, msg(fmt.Sprint("old-", i)))
+			}
+			next := base
+			next.Messages = append([]model.Message(nil), base.Messages...)
+			for i := 0; i < tt.appended; i++ {
+				next.Messages = append(next.Messages, msg(fmt.Sprint("new-", i)))
+			}
+
+			got := appendEvents(base, diffSession(base, next))
+			if len(got.Messages) != tt.wantLen {
+				t.Fatalf("len(messages) = %d, want %d", len(got.Messages), tt.wantLen)
+			}
+			if last := got.Messages[len(got.Messages)-1].Content; last != tt.wantLast {
+				t.Fatalf("last message = %q, want %q", last, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestSessionBase(t *testing.T) {
+	current := &model.Session{ID: "s", Version: 5, State: model.SessionActive}
+	events := []model.SessionEvent{
+		{Seq: 1, Type: model.EventStateChanged, State: model.SessionNew},
+		{Seq: 2, Type: model.EventMessageAdded, Message: &model.Message{Content: "a"}},
+	}
+	eventsUpTo := func(seq int64) ([]model.SessionEvent, error) {
+		var out []model.SessionEvent
+		for _, e := range events {
+			if e.Seq <= seq {
+				out = append(out, e)
+			}
+		}
+		return out, nil
+	}
+	noEvents := func(int64) ([]model.SessionEvent, error) { return nil, nil }
+	trimmed := func(int64) ([]model.SessionEvent, error) { return events[1:], nil }
+
+	tests := []struct {
+		name       string
+		current    *model.Session
+		version    int64
+		eventsUpTo func(int64) ([]model.SessionEvent, error)
+		wantState  model.SessionState
+		wantMsgs   int
+	}{
+		{"new session", nil, 0, eventsUpTo, "", 0},
+		{"not stale", current, 5, eventsUpTo, model.SessionActive, 0},
+		{"stale writer replays to its version", current, 2, eventsUpTo, model.SessionNew, 1},
+		{"stale writer without events uses current", current, 2, noEvents, model.SessionActive, 0},
+		{"trimmed events without snapshot use current", current, 2, trimmed, model.SessionActive, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sessionBase(tt.current, model.Session{ID: "s", Version: tt.version}, tt.eventsUpTo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != tt.wantState || len(got.Messages) != tt.wantMsgs {
+				t.Fatalf("base = %+v, want state %q with %d messages", got, tt.wantState, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreStaleWriters(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Hour, 3)
+	defer store.Close()
+
+	session := &model.Session{ID: "s", UserID: "u", State: model.SessionNew, Messages: []model.Message{}}
+	if err := store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个请求读到同一版本，各自修改后先后保存
+	a, _ := store.Get(ctx, "s")
+	b, _ := store.Get(ctx, "s")
+	a.Messages = append(a.Messages, msg("from a"))
+	a.State, a.FlowID, a.CurrentStep = model.SessionOnFlow, "return_goods", "ask_order_id"
+	b.Messages = append(b.Messages, msg("from b"))
+	b.UpdatedAt = "b"
+	if err := store.SaveWithOptimisticLock(ctx, a, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveWithOptimisticLock(ctx, b, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 落后的 a 再次保存，只记录这次的修改
+	a.CurrentStep = "ask_reason"
+	if err := store.SaveWithOptimisticLock(ctx, a, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := assertReplayMatches(t, store, "s")
+	var contents []string
+	for _, m := range doc.Messages {
+		contents = append(contents, m.Content)
+	}
+	if !reflect.DeepEqual(contents, []string{"from a", "from b"}) {
+		t.Fatalf("messages = %v, want no duplicates or losses", contents)
+	}
+	if doc.State != model.SessionOnFlow || doc.CurrentStep != "ask_reason" || doc.UpdatedAt != "b" {
+		t.Fatalf("merged session = %+v", doc)
+	}
+}
+
+func TestMemoryStoreFlowStartThenEnd(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Hour, 3)
+	defer store.Close()
+
+	session := &model.Session{ID: "s", State: model.SessionActive, Messages: []model.Message{}}
+	steps := []func(s *model.Session){
+		func(s *model.Session) {
+			s.State, s.FlowID, s.CurrentStep = model.SessionOnFlow, "return_goods", "ask_order_id"
+		},
+		func(s *model.Session) {
+			s.CurrentStep = "confirm"
+			s.FlowState = map[string]interface{}{"order_id": "123456"}
+		},
+		func(s *model.Session) {
+			s.State, s.FlowID, s.CurrentStep, s.FlowState = model.SessionComplete, "", "", nil
+		},
+	}
+	if err := store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range steps {
+		step(session)
+		if err := store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, _ := store.Events(ctx, "s")
+	want := []model.SessionEventType{
+		model.EventStateChanged,
+		model.EventFlowStarted, model.EventStateChanged,
+		model.EventStepAdvanced,
+		model.EventFlowEnded, model.EventStateChanged,
+	}
+	if got := eventTypes(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("event types = %v, want %v", got, want)
+	}
+	doc := assertReplayMatches(t, store, "s")
+	if doc.FlowID != "" || doc.FlowState != nil || doc.State != model.SessionComplete {
+		t.Fatalf("flow not ended: %+v", doc)
+	}
+}
+
+func TestMemoryStoreSnapshotTrimsEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Hour, 3)
+	defer store.Close()
+
+	session := &model.Session{ID: "s", Messages: []model.Message{}}
+	var stale *model.Session
+	for i := 0; i < 3*snapshotInterval; i++ {
+		session.Messages = append(session.Messages, msg(fmt.Sprint(i)))
+		if err := store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+			t.Fatal(err)
+		}
+		if i == 3*snapshotInterval-5 {
+			stale, _ = store.Get(ctx, "s")
+		}
+	}
+
+	events, _ := store.Events(ctx, "s")
+	if len(events) > 3*snapshotInterval+1 {
+		t.Fatalf("event stream not trimmed: %d events", len(events))
+	}
+	if events[0].Seq == 1 {
+		t.Fatalf("old events were kept")
+	}
+	doc := assertReplayMatches(t, store, "s")
+	if len(doc.Messages) != maxSessionMessages {
+		t.Fatalf("len(messages) = %d, want %d", len(doc.Messages), maxSessionMessages)
+	}
+
+	// 读取于快照之前的写入仍能回放到自己的版本，只追加自己的消息
+	stale.Messages = append(stale.Messages, msg("stale"))
+	if err := store.SaveWithOptimisticLock(ctx, stale, 3); err != nil {
+		t.Fatal(err)
+	}
+	doc = assertReplayMatches(t, store, "s")
+	if last := doc.Messages[len(doc.Messages)-1].Content; last != "stale" {
+		t.Fatalf("last message = %q, want stale", last)
+	}
+	if prev := doc.Messages[len(doc.Messages)-2].Content; prev != fmt.Sprint(3*snapshotInterval-1) {
+		t.Fatalf("messages after stale save end with %q, %q", prev, "stale")
+	}
+}
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HandoffQueue 等待人工客服接入的会话队列，按转人工时间先后排序
+type HandoffQueue interface {
+	Enqueue(ctx context.Context, sessionID string, at time.Time) error
+	// Claim 将会话移出等待队列，会话不在队列中（已被其他坐席接入）时返回 false
+	Claim(ctx context.Context, sessionID string) (bool, error)
+	Remove(ctx context.Context, sessionID string) error
+	// List 返回等待中的会话 ID，最早转人工的在前
+	List(ctx context.Context) ([]string, error)
+}
+
+var (
+	_ HandoffQueue = (*MemoryHandoffQueue)(nil)
+	_ HandoffQueue = (*RedisHandoffQueue)(nil)
+)
+
+// MemoryHandoffQueue 进程内等待队列
+type MemoryHandoffQueue struct {
+	mu      sync.Mutex
+	waiting map[string]time.Time
+}
+
+func NewMemoryHandoffQueue() *MemoryHandoffQueue {
+	return &MemoryHandoffQueue{waiting: make(map[string]time.Time)}
+}
+
+func (q *MemoryHandoffQueue) Enqueue(ctx context.Context, sessionID string, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.waiting[sessionID] = at
+	return nil
+}
+
+func (q *MemoryHandoffQueue) Claim(ctx context.Context, sessionID string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.waiting[sessionID]; !ok {
+		return false, nil
+	}
+	delete(q.waiting, sessionID)
+	return true, nil
+}
+
+func (q *MemoryHandoffQueue) Remove(ctx context.Context, sessionID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.waiting, sessionID)
+	return nil
+}
+
+func (q *MemoryHandoffQueue) List(ctx context.Context) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, 0, len(q.waiting))
+	for id := range q.waiting {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return q.waiting[ids[i]].Before(q.waiting[ids[j]])
+	})
+	return ids, nil
+}
@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ai-agent/model"
+)
+
+// 定义错误类型
+var (
+	ErrSessionLocked = errors.New("session is locked by another request")
+	ErrStaleFence    = errors.New("stale fencing token: session lease was taken over by a newer request")
+)
+
+// Lease 会话租约，Token 是单调递增的 fencing token，越晚获取的租约越大
+type Lease struct {
+	SessionID string
+	Token     int64
+}
+
+// SessionLocker 会话租约锁，保证同一会话同一时间只有一个请求在处理
+// 租约到期后会自动释放，持有者可能仍在运行，因此保存会话时还要用 Token 校验（见 WithFence）
+type SessionLocker interface {
+	// Acquire 获取租约，会话已被占用时返回 ErrSessionLocked
+	Acquire(ctx context.Context, sessionID string, ttl time.Duration) (Lease, error)
+	// Release 释放租约，租约已过期或已被其他请求获取时不做任何事
+	Release(ctx context.Context, lease Lease) error
+}
+
+var (
+	_ SessionLocker = (*MemorySessionLocker)(nil)
+	_ SessionLocker = (*RedisSessionLocker)(nil)
+)
+
+type fenceKey struct{}
+
+// WithFence 将租约令牌注入 ctx，SessionStore 保存时拒绝比已保存令牌更小的写入
+func WithFence(ctx context.Context, token int64) context.Context {
+	return context.WithValue(ctx, fenceKey{}, token)
+}
+
+// WithoutFence 去掉 ctx 中的租约令牌，用于租约释放后的后台写入
+func WithoutFence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fenceKey{}, nil)
+}
+
+// fenceFrom 取出 ctx 中的租约令牌，未持有租约时返回 false
+func fenceFrom(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(fenceKey{}).(int64)
+	return token, ok
+}
+
+// checkFence 校验 ctx 中的租约令牌，通过后把令牌记到 next 上
+// 已保存的令牌更大说明本请求的租约已过期并被其他请求获取，本次写入必须放弃
+func checkFence(ctx context.Context, current, next *model.Session) error {
+	token, ok := fenceFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if current != nil && current.Fence > token {
+		return fmt.Errorf("%w (session %s, token %d < %d)", ErrStaleFence, next.ID, token, current.Fence)
+	}
+	next.Fence = token
+	return nil
+}
+
+// MemorySessionLocker 进程内会话锁，令牌在进程内单调递增
+type MemorySessionLocker struct {
+	mu     sync.Mutex
+	fence  int64
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	token     int64
+	expiresAt time.Time
+}
+
+func NewMemorySessionLocker() *MemorySessionLocker {
+	return &MemorySessionLocker{leases: make(map[string]memoryLease)}
+}
+
+func (l *MemorySessionLocker) Acquire(ctx context.Context, sessionID string, ttl time.Duration) (Lease, error) {
+	if sessionID == "" {
+		return Lease{}, fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := l.leases[sessionID]; ok && now.Before(lease.expiresAt) {
+		return Lease{}, ErrSessionLocked
+	}
+	l.fence++
+	l.leases[sessionID] = memoryLease{token: l.fence, expiresAt: now.Add(ttl)}
+	return Lease{SessionID: sessionID, Token: l.fence}, nil
+}
+
+func (l *MemorySessionLocker) Release(ctx context.Context, lease Lease) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, ok := l.leases[lease.SessionID]; ok && current.token == lease.Token {
+		delete(l.leases, lease.SessionID)
+	}
+	return nil
+}
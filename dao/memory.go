@@ -0,0 +1,363 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent/model"
+)
+
+// MemoryStore 进程内会话存储，用于本地开发和测试
+// 事件和版本号语义与 RedisStore 保持一致，session 和事件以 JSON 形式保存，读写互不共享内存
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	ttl      time.Duration
+	retries  int // Save 和 UpdateFlowState 的重试次数
+	stop     chan struct{}
+	once     sync.Once
+}
+
+type memoryEntry struct {
+	data      []byte
+	events    []memoryEvent
+	savedAt   time.Time
+	expiresAt time.Time
+}
+
+// memoryEvent 序列化后的事件，seq 用于删除旧事件时不必反序列化
+type memoryEvent struct {
+	seq  int64
+	data []byte
+}
+
+func NewMemoryStore(ttl time.Duration, saveRetries int) *MemoryStore {
+	s := &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		ttl:      ttl,
+		retries:  saveRetries,
+		stop:     make(chan struct{}),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+func (s *MemoryStore) Get(ctx context.Context, sessionID string) (*model.Session, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(sessionID)
+}
+
+func (s *MemoryStore) Save(ctx context.Context, session *model.Session) error {
+	if err := validateSession(session); err != nil {
+		return err
+	}
+
+	return s.saveEvents(ctx, session, false)
+}
+
+func (s *MemoryStore) UpdateFlowState(ctx context.Context, sessionID string, step string, state map[string]interface{}) error {
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.CurrentStep = step
+	session.FlowState = state
+	session.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	return s.SaveWithOptimisticLock(ctx, session, s.retries)
+}
+
+// SaveWithOptimisticLock 使用乐观锁保存session，语义与 RedisStore 一致
+func (s *MemoryStore) SaveWithOptimisticLock(ctx context.Context, session *model.Session, maxRetries int) error {
+	if err := validateSession(session); err != nil {
+		return err
+	}
+	if maxRetries < 0 {
+		return fmt.Errorf("%w: maxRetries cannot be negative", ErrInvalidParam)
+	}
+
+	for i := 0; i <= maxRetries; i++ {
+		err := s.saveEvents(ctx, session, true)
+
+		retry, retryErr := shouldRetry(err)
+		if !retry {
+			return retryErr
+		}
+
+		if i < maxRetries {
+			time.Sleep(time.Millisecond * time.Duration(10*(i+1)))
+			continue
+		}
+
+		return fmt.Errorf("%w for session %s: %v", ErrMaxRetries, session.ID, retryErr)
+	}
+
+	return fmt.Errorf("max retries exceeded for session %s", session.ID)
+}
+
+// saveEvents 在锁内计算并追加事件、写入新的会话文档，相当于 Redis 的 WATCH 事务
+// versioned 为 false 时以当前文档为基准，不回放事件
+func (s *MemoryStore) saveEvents(ctx context.Context, session *model.Session, versioned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.load(session.ID)
+	if err != nil {
+		return err
+	}
+
+	if current == nil && keepTTL(ctx) {
+		// 会话已过期或被删除，后台更新不重新创建
+		return nil
+	}
+
+	base := model.Session{ID: session.ID}
+	if versioned {
+		base, err = sessionBase(current, *session, func(seq int64) ([]model.SessionEvent, error) {
+			return s.loadEvents(session.ID, seq)
+		})
+		if err != nil {
+			return err
+		}
+	} else if current != nil {
+		base = *current
+	}
+
+	next, events, trimBelow := nextSession(current, base, *session)
+	if err := checkFence(ctx, current, &next); err != nil {
+		return err
+	}
+	if err := s.store(&next, events, trimBelow, keepTTL(ctx)); err != nil {
+		return err
+	}
+	session.Version = next.Version
+	return nil
+}
+
+func (s *MemoryStore) Events(ctx context.Context, sessionID string) ([]model.SessionEvent, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, err := s.load(sessionID); err != nil || session == nil {
+		return nil, err
+	}
+	return s.loadEvents(sessionID, 0)
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *MemoryStore) ListByUser(ctx context.Context, userID string, offset, limit int) ([]model.Session, int, error) {
+	if userID == "" {
+		return nil, 0, fmt.Errorf("%w: userID is empty", ErrInvalidParam)
+	}
+	if offset < 0 || limit < 0 {
+		return nil, 0, fmt.Errorf("%w: offset and limit cannot be negative", ErrInvalidParam)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type saved struct {
+		session model.Session
+		savedAt time.Time
+	}
+	var list []saved
+	for id, entry := range s.sessions {
+		session, err := s.load(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if session != nil && session.UserID == userID {
+			list = append(list, saved{*session, entry.savedAt})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].savedAt.After(list[j].savedAt) })
+
+	page := list[min(offset, len(list)):]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+	sessions := make([]model.Session, len(page))
+	for i, item := range page {
+		sessions[i] = item.session
+	}
+	return sessions, len(list), nil
+}
+
+func (s *MemoryStore) DeleteByUser(ctx context.Context, userID string) (int, error) {
+	if userID == "" {
+		return 0, fmt.Errorf("%w: userID is empty", ErrInvalidParam)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id := range s.sessions {
+		session, err := s.load(id)
+		if err != nil {
+			return deleted, err
+		}
+		if session != nil && session.UserID == userID {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) ListExpiring(ctx context.Context, within time.Duration) ([]model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var sessions []model.Session
+	for id, entry := range s.sessions {
+		if entry.expiresAt.IsZero() || s.expired(entry, now) || entry.expiresAt.Sub(now) > within {
+			continue
+		}
+		session, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	select {
+	case <-s.stop:
+		return errors.New("memory store closed")
+	default:
+		return nil
+	}
+}
+
+// load 读取未过期的 session，调用方需持有锁
+func (s *MemoryStore) load(sessionID string) (*model.Session, error) {
+	entry, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	if s.expired(entry, time.Now()) {
+		delete(s.sessions, sessionID)
+		return nil, nil
+	}
+
+	var session model.Session
+	if err := json.Unmarshal(entry.data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// loadEvents 读取序号不超过 upTo 的事件，upTo 为 0 时读取全部，调用方需持有锁
+func (s *MemoryStore) loadEvents(sessionID string, upTo int64) ([]model.SessionEvent, error) {
+	var events []model.SessionEvent
+	for _, e := range s.sessions[sessionID].events {
+		if upTo > 0 && e.seq > upTo {
+			break
+		}
+		var event model.SessionEvent
+		if err := json.Unmarshal(e.data, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// store 序列化并写入 session，追加事件、删除序号小于 trimBelow 的事件，keepTTL 为 false 时刷新过期时间，调用方需持有锁
+func (s *MemoryStore) store(session *model.Session, events []model.SessionEvent, trimBelow int64, keepTTL bool) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prev, exists := s.sessions[session.ID]
+	entry := memoryEntry{data: data, events: prev.events, savedAt: now}
+	for _, event := range events {
+		eventData, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		entry.events = append(entry.events, memoryEvent{seq: event.Seq, data: eventData})
+	}
+	if trimBelow > 0 {
+		keep := slices.IndexFunc(entry.events, func(e memoryEvent) bool { return e.seq >= trimBelow })
+		entry.events = slices.Clone(entry.events[keep:])
+	}
+	switch {
+	case exists && keepTTL:
+		// 不算一次用户活动，过期时间和会话列表中的顺序都不变
+		entry.expiresAt, entry.savedAt = prev.expiresAt, prev.savedAt
+	case s.ttl > 0:
+		entry.expiresAt = now.Add(s.ttl)
+	}
+	s.sessions[session.ID] = entry
+	return nil
+}
+
+func (s *MemoryStore) expired(entry memoryEntry, now time.Time) bool {
+	return !entry.expiresAt.IsZero() && now.After(entry.expiresAt)
+}
+
+// cleanupLoop 定期清理过期 session
+func (s *MemoryStore) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, entry := range s.sessions {
+				if s.expired(entry, now) {
+					delete(s.sessions, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
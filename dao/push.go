@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"sync"
+
+	"ai-agent/model"
+)
+
+// pushBufferSize 每个订阅者的缓冲消息数，消费过慢时丢弃新消息
+const pushBufferSize = 16
+
+// PushBus 会话消息推送总线，用于向连接在任意实例上的客户端推送消息
+type PushBus interface {
+	Publish(ctx context.Context, msg model.PushMessage) error
+	// Subscribe 订阅会话消息，调用返回的 cancel 取消订阅；总线关闭时 channel 会被关闭
+	Subscribe(ctx context.Context, sessionID string) (<-chan model.PushMessage, func(), error)
+	Close() error
+}
+
+var (
+	_ PushBus = (*MemoryPushBus)(nil)
+	_ PushBus = (*RedisPushBus)(nil)
+)
+
+// MemoryPushBus 进程内推送总线，只适用于单实例部署
+type MemoryPushBus struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan model.PushMessage]struct{}
+	closed bool
+}
+
+func NewMemoryPushBus() *MemoryPushBus {
+	return &MemoryPushBus{subs: make(map[string]map[chan model.PushMessage]struct{})}
+}
+
+func (b *MemoryPushBus) Publish(ctx context.Context, msg model.PushMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[msg.SessionID] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *MemoryPushBus) Subscribe(ctx context.Context, sessionID string) (<-chan model.PushMessage, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan model.PushMessage, pushBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	if b.subs[sessionID] == nil {
+		b.subs[sessionID] = make(map[chan model.PushMessage]struct{})
+	}
+	b.subs[sessionID][ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[sessionID][ch]; !ok {
+				return
+			}
+			delete(b.subs[sessionID], ch)
+			if len(b.subs[sessionID]) == 0 {
+				delete(b.subs, sessionID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel, nil
+}
+
+func (b *MemoryPushBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sessionID, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(b.subs, sessionID)
+	}
+	return nil
+}
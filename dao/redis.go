@@ -5,39 +5,44 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"sort"
+	"strings"
 	"time"
 
 	"ai-agent/model"
 	"github.com/go-redis/redis/v8"
 )
 
-// 定义错误类型
-var (
-	ErrSessionConflict = errors.New("session conflict: current session is newer")
-	ErrMaxRetries      = errors.New("max retries exceeded")
-	ErrInvalidSession  = errors.New("invalid session")
-	ErrInvalidParam    = errors.New("invalid parameter")
-)
-
 type RedisStore struct {
-	client    *redis.Client
-	keyPrefix string
-	ttl       time.Duration
+	client         *redis.Client
+	keyPrefix      string
+	eventKeyPrefix string
+	userKeyPrefix  string
+	ttl            time.Duration
+	retries        int // Save 和 UpdateFlowState 的重试次数
 }
 
-func NewRedisStore(addr, password string, db int, ttl time.Duration) *RedisStore {
-	client := redis.NewClient(&redis.Options{
+// NewRedisClient 创建 Redis 客户端，由会话存储和其他 Redis 组件共用
+func NewRedisClient(addr, password string, db int) *redis.Client {
+	return redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
 	})
+}
 
+// NewRedisStore 创建 Redis 会话存储，session key 为 <keyPrefix>session:<id>
+// 会话事件流为 Stream <keyPrefix>session_events:<id>，session key 中保存的是事件回放后的文档
+// 用户的会话索引为 ZSET <keyPrefix>user_sessions:<user_id>，score 为最后保存时间
+// saveRetries 为 Save 和 UpdateFlowState 遇到并发写入时的重试次数
+// 关闭 RedisStore 时会关闭共用的 client，应最后关闭
+func NewRedisStore(client *redis.Client, keyPrefix string, ttl time.Duration, saveRetries int) *RedisStore {
 	return &RedisStore{
-		client:    client,
-		keyPrefix: "ai-agent:session:",
-		ttl:       ttl,
+		client:         client,
+		keyPrefix:      keyPrefix + "session:",
+		eventKeyPrefix: keyPrefix + "session_events:",
+		userKeyPrefix:  keyPrefix + "user_sessions:",
+		ttl:            ttl,
+		retries:        saveRetries,
 	}
 }
 
@@ -63,18 +68,22 @@ func (s *RedisStore) Get(ctx context.Context, sessionID string) (*model.Session,
 	return &session, nil
 }
 
+// Save 以当前文档为基准保存 session，不检查读取时的版本
 func (s *RedisStore) Save(ctx context.Context, session *model.Session) error {
-	if err := s.validateSession(session); err != nil {
+	if err := validateSession(session); err != nil {
 		return err
 	}
+	return s.saveWithRetry(ctx, session, false, s.retries)
+}
 
-	key := s.keyPrefix + session.ID
-	data, err := json.Marshal(session)
-	if err != nil {
-		return err
+// indexUser 把会话写入用户索引，索引与会话使用相同的过期时间
+func (s *RedisStore) indexUser(ctx context.Context, cmd redis.Cmdable, session *model.Session) {
+	if session.UserID == "" {
+		return
 	}
-
-	return s.client.Set(ctx, key, data, s.ttl).Err()
+	userKey := s.userKeyPrefix + session.UserID
+	cmd.ZAdd(ctx, userKey, &redis.Z{Score: float64(time.Now().UnixMilli()), Member: session.ID})
+	cmd.Expire(ctx, userKey, s.ttl)
 }
 
 func (s *RedisStore) UpdateFlowState(ctx context.Context, sessionID string, step string, state map[string]interface{}) error {
@@ -90,71 +99,27 @@ func (s *RedisStore) UpdateFlowState(ctx context.Context, sessionID string, step
 	session.FlowState = state
 	session.UpdatedAt = time.Now().Format(time.RFC3339)
 
-	return s.SaveWithOptimisticLock(ctx, session, 3)
+	return s.SaveWithOptimisticLock(ctx, session, s.retries)
 }
 
-// SaveWithOptimisticLock 使用乐观锁保存session，防止并发覆盖写
+// SaveWithOptimisticLock 使用乐观锁保存session：事件追加到 Redis Stream，会话文档作为投影一起更新
 func (s *RedisStore) SaveWithOptimisticLock(ctx context.Context, session *model.Session, maxRetries int) error {
 	// 参数校验
-	if err := s.validateSession(session); err != nil {
+	if err := validateSession(session); err != nil {
 		return err
 	}
 	if maxRetries < 0 {
 		return fmt.Errorf("%w: maxRetries cannot be negative", ErrInvalidParam)
 	}
+	return s.saveWithRetry(ctx, session, true, maxRetries)
+}
 
-	key := s.keyPrefix + session.ID
-
+func (s *RedisStore) saveWithRetry(ctx context.Context, session *model.Session, versioned bool, maxRetries int) error {
 	for i := 0; i <= maxRetries; i++ {
-		// 使用WATCH监控key
-		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
-			// 获取当前session数据
-			currentData, err := tx.Get(ctx, key).Bytes()
-			if err != nil && !errors.Is(err, redis.Nil) {
-				return err
-			}
-
-			// 如果session不存在，直接保存
-			if errors.Is(err, redis.Nil) {
-				// 设置初始版本号为1
-				session.Version = 1
-				data, err := json.Marshal(session)
-				if err != nil {
-					return err
-				}
-				return tx.Set(ctx, key, data, s.ttl).Err()
-			}
-
-			// 解析当前session数据
-			var currentSession model.Session
-			if err := json.Unmarshal(currentData, &currentSession); err != nil {
-				return err
-			}
-
-			// 版本号检查：如果当前版本号大于等于我们要保存的版本号，说明有冲突
-			if currentSession.Version > session.Version {
-				log.Printf("[Redis] 版本冲突！currentVersion=%d, sessionVersion=%d", currentSession.Version, session.Version)
-				return ErrSessionConflict
-			}
-
-			// 更新版本号：当前版本号+1
-			session.Version = currentSession.Version + 1
-
-			// 智能合并session数据
-			mergedSession := s.mergeSessions(currentSession, *session)
-
-			// 保存合并后的session
-			data, err := json.Marshal(mergedSession)
-			if err != nil {
-				return err
-			}
-
-			// 直接使用Set命令
-			return tx.Set(ctx, key, data, s.ttl).Err()
-		}, key)
+		err := s.saveEvents(ctx, session, versioned)
 
 		// 检查错误类型，决定是否重试
-		shouldRetry, retryErr := s.shouldRetry(err)
+		shouldRetry, retryErr := shouldRetry(err)
 		if !shouldRetry {
 			return retryErr
 		}
@@ -171,138 +136,265 @@ func (s *RedisStore) SaveWithOptimisticLock(ctx context.Context, session *model.
 	return fmt.Errorf("max retries exceeded for session %s", session.ID)
 }
 
-// validateSession 验证session参数
-func (s *RedisStore) validateSession(session *model.Session) error {
-	if session == nil {
-		return fmt.Errorf("%w: session is nil", ErrInvalidSession)
-	}
-	if session.ID == "" {
-		return fmt.Errorf("%w: session.ID is empty", ErrInvalidSession)
-	}
-	return nil
-}
+// saveEvents 在 WATCH 事务中计算事件，追加到事件流并写入新的会话文档
+// 事件 ID 为 0-<seq>，seq 与会话版本号一致；versioned 为 false 时以当前文档为基准
+func (s *RedisStore) saveEvents(ctx context.Context, session *model.Session, versioned bool) error {
+	key := s.keyPrefix + session.ID
+	eventKey := s.eventKeyPrefix + session.ID
+
+	// 事务提交成功后才更新 session.Version，失败重试时仍按原版本号回放
+	var written int64
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		// 获取当前session数据
+		var current *model.Session
+		currentData, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return err
+		default:
+			current = &model.Session{}
+			if err := json.Unmarshal(currentData, current); err != nil {
+				return err
+			}
+		}
 
-// shouldRetry 判断错误是否应该重试
-func (s *RedisStore) shouldRetry(err error) (bool, error) {
-	if err == nil {
-		return false, nil
-	}
+		if current == nil && keepTTL(ctx) {
+			// 会话已过期或被删除，后台更新不重新创建
+			return nil
+		}
 
-	// Redis WATCH事务失败错误
-	if errors.Is(err, redis.TxFailedErr) {
-		return true, err
-	}
+		base := model.Session{ID: session.ID}
+		if versioned {
+			base, err = sessionBase(current, *session, func(seq int64) ([]model.SessionEvent, error) {
+				return readEvents(ctx, tx, eventKey, eventID(seq))
+			})
+			if err != nil {
+				return err
+			}
+		} else if current != nil {
+			base = *current
+		}
 
-	// 自定义的session冲突错误
-	if errors.Is(err, ErrSessionConflict) {
-		return true, err
-	}
+		next, events, trimBelow := nextSession(current, base, *session)
+		if err := checkFence(ctx, current, &next); err != nil {
+			return err
+		}
+		data, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
 
-	// 其他错误不重试
-	return false, err
+		// 事件、会话文档和用户索引在同一个事务中写入
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, event := range events {
+				eventData, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				pipe.XAdd(ctx, &redis.XAddArgs{
+					Stream: eventKey,
+					ID:     eventID(event.Seq),
+					Values: map[string]interface{}{"event": eventData},
+				})
+			}
+			if trimBelow > 0 {
+				pipe.XTrimMinID(ctx, eventKey, eventID(trimBelow))
+			}
+			if keepTTL(ctx) {
+				pipe.Set(ctx, key, data, redis.KeepTTL)
+				return nil
+			}
+			pipe.Set(ctx, key, data, s.ttl)
+			pipe.Expire(ctx, eventKey, s.ttl)
+			s.indexUser(ctx, pipe, &next)
+			return nil
+		})
+		written = next.Version
+		return err
+	}, key, eventKey)
+	if err == nil {
+		session.Version = written
+	}
+	return err
 }
 
-// mergeSessions 智能合并两个session，保持消息顺序和状态一致性
-func (s *RedisStore) mergeSessions(currentSession, newSession model.Session) model.Session {
-	merged := currentSession
+func (s *RedisStore) Events(ctx context.Context, sessionID string) ([]model.SessionEvent, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+	return readEvents(ctx, s.client, s.eventKeyPrefix+sessionID, "+")
+}
 
-	// 1. 合并消息：按时间顺序合并，保持对话上下文
-	merged.Messages = s.mergeMessages(currentSession.Messages, newSession.Messages)
+// eventID 事件序号对应的 Stream ID
+func eventID(seq int64) string {
+	return fmt.Sprintf("0-%d", seq)
+}
 
-	// 2. 状态合并：如果新状态比当前状态更高级，则使用新状态
-	if s.isStateMoreAdvanced(newSession.State, currentSession.State) {
-		merged.State = newSession.State
+// readEvents 读取 ID 不超过 end 的事件
+func readEvents(ctx context.Context, cmd redis.Cmdable, eventKey, end string) ([]model.SessionEvent, error) {
+	msgs, err := cmd.XRange(ctx, eventKey, "-", end).Result()
+	if err != nil {
+		return nil, err
 	}
 
-	// 3. FlowID合并：优先使用新的FlowID
-	if newSession.FlowID != "" {
-		merged.FlowID = newSession.FlowID
+	events := make([]model.SessionEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		data, _ := msg.Values["event"].(string)
+		var event model.SessionEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("event %s: %w", msg.ID, err)
+		}
+		events = append(events, event)
 	}
-	// 4. CurrentStep 合并
-	if newSession.CurrentStep != "" {
-		merged.CurrentStep = newSession.CurrentStep
+	return events, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
 	}
 
-	// 5. FlowState 合并
-	if newSession.FlowState != nil {
-		merged.FlowState = newSession.FlowState
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return err
 	}
 
-	return merged
+	key := s.keyPrefix + sessionID
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key, s.eventKeyPrefix+sessionID)
+		if session != nil && session.UserID != "" {
+			pipe.ZRem(ctx, s.userKeyPrefix+session.UserID, sessionID)
+		}
+		return nil
+	})
+	return err
 }
 
-// mergeMessages 按时间顺序合并消息，保持对话上下文
-func (s *RedisStore) mergeMessages(currentMessages, newMessages []model.Message) []model.Message {
-	// 使用版本号后，消息去重逻辑可以简化
-	// 但为了保持消息顺序和上下文，我们仍然使用时间戳排序
-
-	messageMap := make(map[string]model.Message)
-
-	// 合并所有消息
-	allMessages := append(currentMessages, newMessages...)
+// ListByUser 只读取索引中当前页的会话，total 为索引中的会话数（已过期但未清理的会话也计入，读到时清理）
+func (s *RedisStore) ListByUser(ctx context.Context, userID string, offset, limit int) ([]model.Session, int, error) {
+	if userID == "" {
+		return nil, 0, fmt.Errorf("%w: userID is empty", ErrInvalidParam)
+	}
+	if offset < 0 || limit < 0 {
+		return nil, 0, fmt.Errorf("%w: offset and limit cannot be negative", ErrInvalidParam)
+	}
 
-	for _, msg := range allMessages {
-		msgID := s.generateMessageID(msg)
-		messageMap[msgID] = msg
+	userKey := s.userKeyPrefix + userID
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(offset + limit - 1)
+	}
+	var (
+		idsCmd   *redis.StringSliceCmd
+		totalCmd *redis.IntCmd
+	)
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		idsCmd = pipe.ZRevRange(ctx, userKey, int64(offset), stop)
+		totalCmd = pipe.ZCard(ctx, userKey)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, total := idsCmd.Val(), int(totalCmd.Val())
+	if len(ids) == 0 {
+		return nil, total, nil
 	}
 
-	// 转换为切片
-	result := make([]model.Message, 0, len(messageMap))
-	for _, msg := range messageMap {
-		result = append(result, msg)
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.keyPrefix + id
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// 按时间戳排序
-	sort.Slice(result, func(i, j int) bool {
-		ti, err1 := time.Parse(time.RFC3339Nano, result[i].Timestamp)
-		tj, err2 := time.Parse(time.RFC3339Nano, result[j].Timestamp)
-		if result[i].Timestamp == result[j].Timestamp {
-			return result[i].Role == model.RoleUser
+	sessions := make([]model.Session, 0, len(values))
+	var stale []interface{}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			// 会话已过期，顺便清理索引
+			stale = append(stale, ids[i])
+			continue
 		}
-		if err1 == nil && err2 == nil {
-			return ti.Before(tj) // 早的在前
+		var session model.Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, 0, err
 		}
-		return result[i].Timestamp > result[j].Timestamp
-	})
-
-	return result
-}
-
-// generateMessageID 生成消息的唯一ID
-// 使用Role+Content+Timestamp作为ID，避免误判重复
-// 使用Role+Content作为唯一标识符
-func (s *RedisStore) generateMessageID(msg model.Message) string {
-	return fmt.Sprintf("%s:%s:%s", msg.Role, msg.Content, msg.Timestamp)
+		sessions = append(sessions, session)
+	}
+	if len(stale) > 0 {
+		if err := s.client.ZRem(ctx, userKey, stale...).Err(); err != nil {
+			return nil, 0, err
+		}
+		total -= len(stale)
+	}
+	return sessions, total, nil
 }
 
-// isStateMoreAdvanced 检查状态stateA是否比stateB更"高级"
-// 返回true表示stateA比stateB更高级
-func (s *RedisStore) isStateMoreAdvanced(stateA, stateB model.SessionState) bool {
-	stateOrder := map[model.SessionState]int{
-		model.SessionNew:      0,
-		model.SessionActive:   1,
-		model.SessionOnFlow:   2,
-		model.SessionComplete: 3,
+func (s *RedisStore) DeleteByUser(ctx context.Context, userID string) (int, error) {
+	if userID == "" {
+		return 0, fmt.Errorf("%w: userID is empty", ErrInvalidParam)
 	}
 
-	orderA, existsA := stateOrder[stateA]
-	orderB, existsB := stateOrder[stateB]
+	userKey := s.userKeyPrefix + userID
+	ids, err := s.client.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
 
-	// 如果状态未知，默认认为相等，不进行替换
-	if !existsA || !existsB {
-		return false
+	keys := make([]string, len(ids))
+	eventKeys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.keyPrefix + id
+		eventKeys[i] = s.eventKeyPrefix + id
 	}
 
-	return orderA > orderB
+	var deleted *redis.IntCmd
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(keys) > 0 {
+			deleted = pipe.Del(ctx, keys...)
+			pipe.Del(ctx, eventKeys...)
+		}
+		pipe.Del(ctx, userKey)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if deleted == nil {
+		return 0, nil
+	}
+	return int(deleted.Val()), nil
 }
 
-func (s *RedisStore) Delete(ctx context.Context, sessionID string) error {
-	if sessionID == "" {
-		return fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
-	}
+// ListExpiring 用 SCAN 遍历会话 key，返回剩余 TTL 不超过 within 的会话
+func (s *RedisStore) ListExpiring(ctx context.Context, within time.Duration) ([]model.Session, error) {
+	var sessions []model.Session
+	iter := s.client.Scan(ctx, 0, s.keyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		ttl, err := s.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		// -1 表示没有过期时间，-2 表示已经不存在
+		if ttl < 0 || ttl > within {
+			continue
+		}
 
-	key := s.keyPrefix + sessionID
-	return s.client.Del(ctx, key).Err()
+		session, err := s.Get(ctx, strings.TrimPrefix(key, s.keyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, iter.Err()
 }
 
 func (s *RedisStore) Close() error {
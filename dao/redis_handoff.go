@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisHandoffQueue 基于 Redis 有序集合的等待队列，key 为 <keyPrefix>handoff:waiting
+// ZREM 是原子操作，多个坐席同时接入同一会话时只有一个能成功
+type RedisHandoffQueue struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisHandoffQueue(client *redis.Client, keyPrefix string) *RedisHandoffQueue {
+	return &RedisHandoffQueue{
+		client: client,
+		key:    keyPrefix + "handoff:waiting",
+	}
+}
+
+func (q *RedisHandoffQueue) Enqueue(ctx context.Context, sessionID string, at time.Time) error {
+	return q.client.ZAdd(ctx, q.key, &redis.Z{Score: float64(at.UnixNano()), Member: sessionID}).Err()
+}
+
+func (q *RedisHandoffQueue) Claim(ctx context.Context, sessionID string) (bool, error) {
+	removed, err := q.client.ZRem(ctx, q.key, sessionID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed == 1, nil
+}
+
+func (q *RedisHandoffQueue) Remove(ctx context.Context, sessionID string) error {
+	return q.client.ZRem(ctx, q.key, sessionID).Err()
+}
+
+func (q *RedisHandoffQueue) List(ctx context.Context) ([]string, error) {
+	return q.client.ZRange(ctx, q.key, 0, -1).Result()
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// releaseScript 只有锁的值仍是自己的令牌时才删除，避免释放已被其他请求获取的锁
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisSessionLocker 基于 SET NX PX 的会话租约锁，锁 key 为 <keyPrefix>session_lock:<id>
+// 令牌来自全局计数器 <keyPrefix>session_lock_fence，不设过期时间，保证跨实例单调递增
+type RedisSessionLocker struct {
+	client    *redis.Client
+	keyPrefix string
+	fenceKey  string
+}
+
+func NewRedisSessionLocker(client *redis.Client, keyPrefix string) *RedisSessionLocker {
+	return &RedisSessionLocker{
+		client:    client,
+		keyPrefix: keyPrefix + "session_lock:",
+		fenceKey:  keyPrefix + "session_lock_fence",
+	}
+}
+
+func (l *RedisSessionLocker) Acquire(ctx context.Context, sessionID string, ttl time.Duration) (Lease, error) {
+	if sessionID == "" {
+		return Lease{}, fmt.Errorf("%w: sessionID is empty", ErrInvalidParam)
+	}
+
+	token, err := l.client.Incr(ctx, l.fenceKey).Result()
+	if err != nil {
+		return Lease{}, err
+	}
+	ok, err := l.client.SetNX(ctx, l.keyPrefix+sessionID, token, ttl).Result()
+	if err != nil {
+		return Lease{}, err
+	}
+	if !ok {
+		return Lease{}, ErrSessionLocked
+	}
+	return Lease{SessionID: sessionID, Token: token}, nil
+}
+
+func (l *RedisSessionLocker) Release(ctx context.Context, lease Lease) error {
+	return releaseScript.Run(ctx, l.client, []string{l.keyPrefix + lease.SessionID}, lease.Token).Err()
+}
@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"ai-agent/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisPushBus 基于 Redis pub/sub 的推送总线，多实例部署时消息可以到达任意实例上的连接
+type RedisPushBus struct {
+	client        *redis.Client
+	channelPrefix string
+
+	mu     sync.Mutex
+	subs   map[*redis.PubSub]struct{}
+	closed bool
+}
+
+// NewRedisPushBus 创建推送总线，频道为 <keyPrefix>push:<sessionID>
+func NewRedisPushBus(client *redis.Client, keyPrefix string) *RedisPushBus {
+	return &RedisPushBus{
+		client:        client,
+		channelPrefix: keyPrefix + "push:",
+		subs:          make(map[*redis.PubSub]struct{}),
+	}
+}
+
+func (b *RedisPushBus) Publish(ctx context.Context, msg model.PushMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channelPrefix+msg.SessionID, data).Err()
+}
+
+func (b *RedisPushBus) Subscribe(ctx context.Context, sessionID string) (<-chan model.PushMessage, func(), error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		ch := make(chan model.PushMessage)
+		close(ch)
+		return ch, func() {}, nil
+	}
+	b.mu.Unlock()
+
+	ps := b.client.Subscribe(ctx, b.channelPrefix+sessionID)
+	// 等待订阅确认，确保返回后发布的消息不会丢失
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, nil, err
+	}
+
+	b.mu.Lock()
+	b.subs[ps] = struct{}{}
+	b.mu.Unlock()
+
+	out := make(chan model.PushMessage, pushBufferSize)
+	go func() {
+		defer close(out)
+		for m := range ps.Channel() {
+			var msg model.PushMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				log.Printf("[RedisPush] 解析推送消息失败: %v", err)
+				continue
+			}
+			select {
+			case out <- msg:
+			default:
+				log.Printf("[RedisPush] 会话 %s 消费过慢，丢弃推送消息", sessionID)
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ps)
+			b.mu.Unlock()
+			ps.Close()
+		})
+	}
+	return out, cancel, nil
+}
+
+// Close 关闭所有订阅，不关闭共用的 Redis client
+func (b *RedisPushBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ps := range b.subs {
+		ps.Close()
+		delete(b.subs, ps)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-agent/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// replyPendingPrefix 处理中标记的前缀，后面是占用时间（毫秒时间戳）
+const replyPendingPrefix = "pending:"
+
+// claimScript 消息 ID 未记录或处理中标记已过期时写入新的标记并返回 false，否则返回已记录的值
+var claimScript = redis.NewScript(`
+local v = redis.call("HGET", KEYS[1], ARGV[1])
+if v and (string.sub(v, 1, 8) ~= "pending:" or tonumber(string.sub(v, 9)) > tonumber(ARGV[3])) then
+	return v
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return false
+`)
+
+// releaseReplyScript 只删除处理中标记，不删除已记录的回复
+var releaseReplyScript = redis.NewScript(`
+local v = redis.call("HGET", KEYS[1], ARGV[1])
+if v and string.sub(v, 1, 8) == "pending:" then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// RedisReplyCache 基于 Redis 哈希的回复缓存，key 为 <keyPrefix>session_replies:<id>，field 为消息 ID
+// field 的值为回复 JSON，或处理中标记 pending:<毫秒时间戳>；每次写入刷新过期时间，与会话使用相同的 TTL
+// pendingTimeout 为处理中标记的有效期，超过后视为处理请求已退出，允许重新占用
+type RedisReplyCache struct {
+	client         *redis.Client
+	keyPrefix      string
+	ttl            time.Duration
+	pendingTimeout time.Duration
+}
+
+func NewRedisReplyCache(client *redis.Client, keyPrefix string, ttl, pendingTimeout time.Duration) *RedisReplyCache {
+	return &RedisReplyCache{
+		client:         client,
+		keyPrefix:      keyPrefix + "session_replies:",
+		ttl:            ttl,
+		pendingTimeout: pendingTimeout,
+	}
+}
+
+func (c *RedisReplyCache) Claim(ctx context.Context, sessionID, messageID string) (*model.ChatResponse, error) {
+	now := time.Now()
+	v, err := claimScript.Run(ctx, c.client, []string{c.keyPrefix + sessionID},
+		messageID,
+		replyPendingPrefix+strconv.FormatInt(now.UnixMilli(), 10),
+		now.Add(-c.pendingTimeout).UnixMilli(),
+		c.ttl.Milliseconds(),
+	).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(v, replyPendingPrefix) {
+		return nil, ErrReplyPending
+	}
+
+	var resp model.ChatResponse
+	if err := json.Unmarshal([]byte(v), &resp); err != nil {
+		return nil, fmt.Errorf("reply of message %s: %w", messageID, err)
+	}
+	return &resp, nil
+}
+
+func (c *RedisReplyCache) Put(ctx context.Context, sessionID, messageID string, resp *model.ChatResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	key := c.keyPrefix + sessionID
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, messageID, data)
+		pipe.Expire(ctx, key, c.ttl)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisReplyCache) Release(ctx context.Context, sessionID, messageID string) error {
+	return releaseReplyScript.Run(ctx, c.client, []string{c.keyPrefix + sessionID}, messageID).Err()
+}
+
+func (c *RedisReplyCache) Delete(ctx context.Context, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(sessionIDs))
+	for i, id := range sessionIDs {
+		keys[i] = c.keyPrefix + id
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// Close 连接由会话存储统一关闭
+func (c *RedisReplyCache) Close() error {
+	return nil
+}
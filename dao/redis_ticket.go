@@ -0,0 +1,221 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisTicketStore 基于 Redis 的工单存储
+// 工单保存在 <keyPrefix>ticket:<id>，不过期；
+// 按创建时间排序的索引保存在 <keyPrefix>tickets:all 和 <keyPrefix>tickets:user:<userID>
+type RedisTicketStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func NewRedisTicketStore(client *redis.Client, keyPrefix string) *RedisTicketStore {
+	return &RedisTicketStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *RedisTicketStore) ticketKey(id string) string {
+	return s.keyPrefix + "ticket:" + id
+}
+
+func (s *RedisTicketStore) indexKey(userID string) string {
+	if userID == "" {
+		return s.keyPrefix + "tickets:all"
+	}
+	return s.keyPrefix + "tickets:user:" + userID
+}
+
+func (s *RedisTicketStore) Create(ctx context.Context, ticket *model.Ticket) error {
+	if ticket == nil || ticket.ID == "" {
+		return fmt.Errorf("%w: ticket.ID is empty", ErrInvalidParam)
+	}
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+
+	score := float64(time.Now().UnixNano())
+	if t, err := time.Parse(time.RFC3339Nano, ticket.CreatedAt); err == nil {
+		score = float64(t.UnixNano())
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.ticketKey(ticket.ID), data, 0)
+		pipe.ZAdd(ctx, s.indexKey(""), &redis.Z{Score: score, Member: ticket.ID})
+		if ticket.UserID != "" {
+			pipe.ZAdd(ctx, s.indexKey(ticket.UserID), &redis.Z{Score: score, Member: ticket.ID})
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *RedisTicketStore) Get(ctx context.Context, id string) (*model.Ticket, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: ticket id is empty", ErrInvalidParam)
+	}
+
+	data, err := s.client.Get(ctx, s.ticketKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ticket model.Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// ticketScanBatch 按状态过滤时每批从索引读取的工单数
+const ticketScanBatch = 100
+
+// List 不按状态过滤时直接对索引分页，只读取当前页的工单；
+// 状态只保存在工单里，按状态过滤时分批读取索引中的工单并计数
+func (s *RedisTicketStore) List(ctx context.Context, filter TicketFilter) ([]model.Ticket, int, error) {
+	if filter.Offset < 0 || filter.Limit < 0 {
+		return nil, 0, fmt.Errorf("%w: offset and limit cannot be negative", ErrInvalidParam)
+	}
+	indexKey := s.indexKey(filter.UserID)
+
+	if filter.Status == "" {
+		stop := int64(-1)
+		if filter.Limit > 0 {
+			stop = int64(filter.Offset + filter.Limit - 1)
+		}
+		var (
+			idsCmd   *redis.StringSliceCmd
+			totalCmd *redis.IntCmd
+		)
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			idsCmd = pipe.ZRevRange(ctx, indexKey, int64(filter.Offset), stop)
+			totalCmd = pipe.ZCard(ctx, indexKey)
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		tickets, err := s.getMany(ctx, idsCmd.Val())
+		return tickets, int(totalCmd.Val()), err
+	}
+
+	result := make([]model.Ticket, 0)
+	total := 0
+	for start := int64(0); ; start += ticketScanBatch {
+		ids, err := s.client.ZRevRange(ctx, indexKey, start, start+ticketScanBatch-1).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		tickets, err := s.getMany(ctx, ids)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, ticket := range tickets {
+			if !matchTicket(&ticket, filter) {
+				continue
+			}
+			total++
+			if total > filter.Offset && (filter.Limit == 0 || len(result) < filter.Limit) {
+				result = append(result, ticket)
+			}
+		}
+		if len(ids) < ticketScanBatch {
+			return result, total, nil
+		}
+	}
+}
+
+// getMany 用一次 MGET 读取工单，跳过不存在的 ID
+func (s *RedisTicketStore) getMany(ctx context.Context, ids []string) ([]model.Ticket, error) {
+	tickets := make([]model.Ticket, 0, len(ids))
+	if len(ids) == 0 {
+		return tickets, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.ticketKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var ticket model.Ticket
+		if err := json.Unmarshal([]byte(data), &ticket); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, nil
+}
+
+// Update 使用 WATCH 保证读改写的原子性，并发修改时重试
+func (s *RedisTicketStore) Update(ctx context.Context, id string, fn func(ticket *model.Ticket) error) (*model.Ticket, error) {
+	key := s.ticketKey(id)
+
+	var updated *model.Ticket
+	for i := 0; i <= 3; i++ {
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if errors.Is(err, redis.Nil) {
+				return ErrTicketNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			var ticket model.Ticket
+			if err := json.Unmarshal(data, &ticket); err != nil {
+				return err
+			}
+			if err := fn(&ticket); err != nil {
+				return err
+			}
+
+			newData, err := json.Marshal(&ticket)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, newData, 0)
+				return nil
+			})
+			if err == nil {
+				updated = &ticket
+			}
+			return err
+		}, key)
+
+		if errors.Is(err, redis.TxFailedErr) {
+			time.Sleep(time.Millisecond * time.Duration(10*(i+1)))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+
+	return nil, fmt.Errorf("%w for ticket %s", ErrMaxRetries, id)
+}
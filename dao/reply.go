@@ -0,0 +1,164 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"ai-agent/model"
+)
+
+// ErrReplyPending 同一消息 ID 正在被其他请求处理
+var ErrReplyPending = errors.New("message is being processed by another request")
+
+// ReplyCache 记录每个会话已处理的客户端消息 ID 及其回复，客户端重试时直接返回原回复
+// 处理前先用 Claim 写入处理中标记，保证同一消息 ID 只执行一次
+type ReplyCache interface {
+	// Claim 占用消息 ID：未处理过时写入处理中标记并返回 nil；已处理时返回原回复；
+	// 其他请求正在处理时返回 ErrReplyPending
+	Claim(ctx context.Context, sessionID, messageID string) (*model.ChatResponse, error)
+	// Put 用最终回复替换处理中标记
+	Put(ctx context.Context, sessionID, messageID string, resp *model.ChatResponse) error
+	// Release 删除处理中标记，本轮处理失败时调用，客户端可以重试
+	Release(ctx context.Context, sessionID, messageID string) error
+	// Delete 删除会话的全部记录
+	Delete(ctx context.Context, sessionIDs ...string) error
+	Close() error
+}
+
+var (
+	_ ReplyCache = (*MemoryReplyCache)(nil)
+	_ ReplyCache = (*RedisReplyCache)(nil)
+)
+
+// MemoryReplyCache 进程内回复缓存，会话最后一次写入 ttl 后整体过期
+// pendingTimeout 为处理中标记的有效期，超过后视为处理请求已退出，允许重新占用
+type MemoryReplyCache struct {
+	mu             sync.Mutex
+	ttl            time.Duration
+	pendingTimeout time.Duration
+	sessions       map[string]*memoryReplies
+	stop           chan struct{}
+	once           sync.Once
+}
+
+type memoryReplies struct {
+	replies   map[string]memoryReply
+	expiresAt time.Time
+}
+
+// memoryReply resp 为 nil 时表示处理中，pendingSince 为占用时间
+type memoryReply struct {
+	resp         *model.ChatResponse
+	pendingSince time.Time
+}
+
+func NewMemoryReplyCache(ttl, pendingTimeout time.Duration) *MemoryReplyCache {
+	c := &MemoryReplyCache{
+		ttl:            ttl,
+		pendingTimeout: pendingTimeout,
+		sessions:       make(map[string]*memoryReplies),
+		stop:           make(chan struct{}),
+	}
+	go c.cleanupLoop()
+	return c
+}
+
+func (c *MemoryReplyCache) Claim(ctx context.Context, sessionID, messageID string) (*model.ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entry := c.entry(sessionID, now)
+	if reply, ok := entry.replies[messageID]; ok {
+		if reply.resp != nil {
+			resp := *reply.resp
+			return &resp, nil
+		}
+		if now.Sub(reply.pendingSince) < c.pendingTimeout {
+			return nil, ErrReplyPending
+		}
+	}
+	entry.replies[messageID] = memoryReply{pendingSince: now}
+	entry.expiresAt = now.Add(c.ttl)
+	return nil, nil
+}
+
+func (c *MemoryReplyCache) Put(ctx context.Context, sessionID, messageID string, resp *model.ChatResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entry := c.entry(sessionID, now)
+	saved := *resp
+	entry.replies[messageID] = memoryReply{resp: &saved}
+	entry.expiresAt = now.Add(c.ttl)
+	return nil
+}
+
+func (c *MemoryReplyCache) Release(ctx context.Context, sessionID, messageID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.sessions[sessionID]; ok && entry.replies[messageID].resp == nil {
+		delete(entry.replies, messageID)
+	}
+	return nil
+}
+
+func (c *MemoryReplyCache) Delete(ctx context.Context, sessionIDs ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range sessionIDs {
+		delete(c.sessions, id)
+	}
+	return nil
+}
+
+func (c *MemoryReplyCache) Close() error {
+	c.once.Do(func() { close(c.stop) })
+	return nil
+}
+
+// cleanupLoop 定期清理过期会话的记录，不再访问的会话也会被释放
+func (c *MemoryReplyCache) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.sweep(now)
+		}
+	}
+}
+
+// sweep 删除 now 时已过期的会话记录
+func (c *MemoryReplyCache) sweep(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, entry := range c.sessions {
+		if now.After(entry.expiresAt) {
+			delete(c.sessions, id)
+		}
+	}
+}
+
+// entry 返回会话未过期的记录，不存在或已过期时新建，调用方需持有锁
+func (c *MemoryReplyCache) entry(sessionID string, now time.Time) *memoryReplies {
+	entry, ok := c.sessions[sessionID]
+	if !ok || (c.ttl > 0 && now.After(entry.expiresAt)) {
+		entry = &memoryReplies{replies: make(map[string]memoryReply)}
+		c.sessions[sessionID] = entry
+	}
+	return entry
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ai-agent/model"
+)
+
+func TestMemoryReplyCachePendingTimeout(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryReplyCache(time.Hour, 20*time.Millisecond)
+	t.Cleanup(func() { cache.Close() })
+
+	if resp, err := cache.Claim(ctx, "s1", "m1"); resp != nil || err != nil {
+		t.Fatalf("first Claim = %v, %v", resp, err)
+	}
+	if _, err := cache.Claim(ctx, "s1", "m1"); !errors.Is(err, ErrReplyPending) {
+		t.Fatalf("second Claim err = %v, want ErrReplyPending", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if resp, err := cache.Claim(ctx, "s1", "m1"); resp != nil || err != nil {
+		t.Fatalf("Claim after pending timeout = %v, %v, want a new claim", resp, err)
+	}
+
+	if err := cache.Put(ctx, "s1", "m1", &model.ChatResponse{Reply: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if resp, err := cache.Claim(ctx, "s1", "m1"); err != nil || resp == nil || resp.Reply != "ok" {
+		t.Fatalf("Claim after Put = %v, %v, want the saved reply", resp, err)
+	}
+}
+
+func TestMemoryReplyCacheSweep(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryReplyCache(time.Minute, time.Minute)
+	t.Cleanup(func() { cache.Close() })
+
+	for _, id := range []string{"s1", "s2"} {
+		if err := cache.Put(ctx, id, "m1", &model.ChatResponse{Reply: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.mu.Lock()
+	cache.sessions["s1"].expiresAt = time.Now().Add(-time.Second)
+	cache.mu.Unlock()
+
+	cache.sweep(time.Now())
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.sessions["s1"]; ok {
+		t.Error("expired session s1 was not swept")
+	}
+	if _, ok := cache.sessions["s2"]; !ok {
+		t.Error("live session s2 was swept")
+	}
+}
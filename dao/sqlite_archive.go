@@ -0,0 +1,127 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ai-agent/model"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteArchiveSchema = `
+CREATE TABLE IF NOT EXISTS archived_sessions (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id  TEXT    NOT NULL,
+	user_id     TEXT    NOT NULL,
+	reason      TEXT    NOT NULL,
+	archived_at INTEGER NOT NULL, -- Unix 毫秒
+	data        TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_archived_sessions_session ON archived_sessions (session_id);
+CREATE INDEX IF NOT EXISTS idx_archived_sessions_user ON archived_sessions (user_id, archived_at);
+CREATE INDEX IF NOT EXISTS idx_archived_sessions_time ON archived_sessions (archived_at);
+`
+
+// SQLiteArchive 以 SQLite 数据库归档，适合按用户和时间范围频繁查询的场景
+type SQLiteArchive struct {
+	db *sql.DB
+}
+
+// NewSQLiteArchive 打开（不存在时创建）SQLite 归档数据库
+func NewSQLiteArchive(path string) (*SQLiteArchive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteArchiveSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化归档表失败: %w", err)
+	}
+	return &SQLiteArchive{db: db}, nil
+}
+
+func (a *SQLiteArchive) Append(ctx context.Context, record model.ArchivedSession) error {
+	archivedAt, err := time.Parse(time.RFC3339Nano, record.ArchivedAt)
+	if err != nil {
+		return fmt.Errorf("%w: archived_at %q", ErrInvalidParam, record.ArchivedAt)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.ExecContext(ctx,
+		`INSERT INTO archived_sessions (session_id, user_id, reason, archived_at, data) VALUES (?, ?, ?, ?, ?)`,
+		record.SessionID, record.UserID, string(record.Reason), archivedAt.UnixMilli(), string(data))
+	return err
+}
+
+func (a *SQLiteArchive) Query(ctx context.Context, filter ArchiveFilter) ([]model.ArchivedSession, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.SessionID != "" {
+		where = append(where, "session_id = ?")
+		args = append(args, filter.SessionID)
+	}
+	if filter.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "archived_at >= ?")
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "archived_at < ?")
+		args = append(args, filter.To.UnixMilli())
+	}
+
+	query := "SELECT data FROM archived_sessions"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY archived_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []model.ArchivedSession
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var record model.ArchivedSession
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (a *SQLiteArchive) Close() error {
+	return a.db.Close()
+}
@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ai-agent/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// 定义错误类型
+var (
+	ErrSessionConflict = errors.New("session conflict: current session is newer")
+	ErrMaxRetries      = errors.New("max retries exceeded")
+	ErrInvalidSession  = errors.New("invalid session")
+	ErrInvalidParam    = errors.New("invalid parameter")
+)
+
+// SessionStore 会话存储接口，RedisStore 和 MemoryStore 都实现该接口
+type SessionStore interface {
+	Get(ctx context.Context, sessionID string) (*model.Session, error)
+	Save(ctx context.Context, session *model.Session) error
+	// SaveWithOptimisticLock 把 session 相对读取时版本的修改记为事件，追加到事件流并更新会话文档
+	SaveWithOptimisticLock(ctx context.Context, session *model.Session, maxRetries int) error
+	// Events 返回会话的全部事件，按序号从小到大
+	Events(ctx context.Context, sessionID string) ([]model.SessionEvent, error)
+	UpdateFlowState(ctx context.Context, sessionID string, step string, state map[string]interface{}) error
+	Delete(ctx context.Context, sessionID string) error
+	// ListByUser 返回用户最近保存的在前的第 offset 个起的 limit 个会话（limit 为 0 时返回之后全部）以及会话总数
+	ListByUser(ctx context.Context, userID string, offset, limit int) ([]model.Session, int, error)
+	// DeleteByUser 删除用户的全部会话，返回删除的数量
+	DeleteByUser(ctx context.Context, userID string) (int, error)
+	// ListExpiring 返回将在 within 内过期的会话
+	ListExpiring(ctx context.Context, within time.Duration) ([]model.Session, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var (
+	_ SessionStore = (*RedisStore)(nil)
+	_ SessionStore = (*MemoryStore)(nil)
+)
+
+type keepTTLKey struct{}
+
+// WithKeepTTL 保存时保留会话剩余的过期时间，用于后台任务更新会话而不延长其生命周期
+func WithKeepTTL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keepTTLKey{}, true)
+}
+
+func keepTTL(ctx context.Context) bool {
+	keep, _ := ctx.Value(keepTTLKey{}).(bool)
+	return keep
+}
+
+// validateSession 验证session参数
+func validateSession(session *model.Session) error {
+	if session == nil {
+		return fmt.Errorf("%w: session is nil", ErrInvalidSession)
+	}
+	if session.ID == "" {
+		return fmt.Errorf("%w: session.ID is empty", ErrInvalidSession)
+	}
+	return nil
+}
+
+// shouldRetry 判断错误是否应该重试
+func shouldRetry(err error) (bool, error) {
+	if err == nil {
+		return false, nil
+	}
+
+	// Redis WATCH事务失败错误
+	if errors.Is(err, redis.TxFailedErr) {
+		return true, err
+	}
+
+	// 自定义的session冲突错误
+	if errors.Is(err, ErrSessionConflict) {
+		return true, err
+	}
+
+	// 其他错误不重试
+	return false, err
+}
+
+// sessionBase 返回 session 被读取时的版本，用于计算本次修改产生的事件
+// 读取之后没有其他写入时就是当前文档，否则通过 eventsUpTo 从最近的快照回放到 session.Version
+func sessionBase(current *model.Session, session model.Session, eventsUpTo func(seq int64) ([]model.SessionEvent, error)) (model.Session, error) {
+	if current == nil || session.Version == 0 {
+		return model.Session{ID: session.ID}, nil
+	}
+	if current.Version == session.Version {
+		return *current, nil
+	}
+
+	events, err := eventsUpTo(session.Version)
+	if err != nil {
+		return model.Session{}, err
+	}
+	base, ok := replayEvents(session.ID, events)
+	if !ok {
+		// 没有事件流的旧会话，或读取的版本早于保留的事件，只能以当前文档为准
+		log.Printf("[Store] session %s 无法回放到版本 %d，以当前文档为基准", session.ID, session.Version)
+		return *current, nil
+	}
+	log.Printf("[Store] session %s 版本落后 (stored=%d, read=%d), 回放事件计算本次修改", session.ID, current.Version, session.Version)
+	return base, nil
+}
+
+// nextSession 计算 session 相对 base 的事件，追加到当前文档之后得到新的文档
+// 需要写快照时快照是最后一个事件，trimBelow 大于 0 表示序号小于它的事件可以删除
+// 写入成功后调用方需要把 session.Version 更新为返回值的版本号，便于同一请求内再次保存
+func nextSession(current *model.Session, base, session model.Session) (next model.Session, events []model.SessionEvent, trimBelow int64) {
+	next = model.Session{ID: session.ID, Messages: []model.Message{}}
+	if current != nil {
+		next = *current
+	}
+	prevVersion := next.Version
+	events = diffSession(base, session)
+	next = appendEvents(next, events)
+
+	if snapshot, below := snapshotEvent(prevVersion, next); snapshot != nil {
+		events = append(events, *snapshot)
+		next.Version = snapshot.Seq
+		trimBelow = below
+	}
+	return next, events, trimBelow
+}
@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ai-agent/model"
+)
+
+func TestSaveWithOptimisticLock(t *testing.T) {
+	ts := func(sec int) string {
+		return time.Date(2024, 1, 1, 0, 0, sec, 0, time.UTC).Format(time.RFC3339Nano)
+	}
+	onFlow := func() *model.Session {
+		return &model.Session{
+			ID:          "s",
+			UserID:      "u",
+			State:       model.SessionOnFlow,
+			FlowID:      "return_goods",
+			CurrentStep: "ask_reason",
+			FlowState:   map[string]interface{}{"order_id": "12345678"},
+			Messages:    []model.Message{{Role: model.RoleUser, Content: "a", Timestamp: ts(1)}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		update func(ctx context.Context, store *MemoryStore) error
+		check  func(t *testing.T, got *model.Session)
+	}{
+		{
+			name: "cleared flow survives a save",
+			update: func(ctx context.Context, store *MemoryStore) error {
+				session, err := store.Get(ctx, "s")
+				if err != nil {
+					return err
+				}
+				session.State = model.SessionComplete
+				session.FlowID = ""
+				session.CurrentStep = ""
+				session.FlowState = nil
+				return store.SaveWithOptimisticLock(ctx, session, 3)
+			},
+			check: func(t *testing.T, got *model.Session) {
+				if got.State != model.SessionComplete || got.FlowID != "" || got.CurrentStep != "" || len(got.FlowState) != 0 {
+					t.Fatalf("flow not cleared: state=%s flow=%q step=%q flow_state=%v",
+						got.State, got.FlowID, got.CurrentStep, got.FlowState)
+				}
+			},
+		},
+		{
+			name: "second save in the same request is not stale",
+			update: func(ctx context.Context, store *MemoryStore) error {
+				session, err := store.Get(ctx, "s")
+				if err != nil {
+					return err
+				}
+				session.CurrentStep = "confirm"
+				if err := store.SaveWithOptimisticLock(ctx, session, 0); err != nil {
+					return err
+				}
+				session.FlowID = ""
+				session.CurrentStep = ""
+				return store.SaveWithOptimisticLock(ctx, session, 0)
+			},
+			check: func(t *testing.T, got *model.Session) {
+				if got.FlowID != "" || got.CurrentStep != "" {
+					t.Fatalf("second save lost: flow=%q step=%q", got.FlowID, got.CurrentStep)
+				}
+			},
+		},
+		{
+			name: "stale writer is merged instead of rejected",
+			update: func(ctx context.Context, store *MemoryStore) error {
+				first, err := store.Get(ctx, "s")
+				if err != nil {
+					return err
+				}
+				stale, err := store.Get(ctx, "s")
+				if err != nil {
+					return err
+				}
+				first.Messages = append(first.Messages, model.Message{Role: model.RoleUser, Content: "b", Timestamp: ts(2)})
+				if err := store.SaveWithOptimisticLock(ctx, first, 0); err != nil {
+					return err
+				}
+				stale.Messages = append(stale.Messages, model.Message{Role: model.RoleUser, Content: "c", Timestamp: ts(3)})
+				return store.SaveWithOptimisticLock(ctx, stale, 0)
+			},
+			check: func(t *testing.T, got *model.Session) {
+				var contents []string
+				for _, m := range got.Messages {
+					contents = append(contents, m.Content)
+				}
+				if len(contents) != 3 || contents[0] != "a" || contents[1] != "b" || contents[2] != "c" {
+					t.Fatalf("messages = %v, want [a b c]", contents)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore(time.Hour, 3)
+			defer store.Close()
+
+			if err := store.Save(ctx, onFlow()); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := tt.update(ctx, store); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			got, err := store.Get(ctx, "s")
+			if err != nil || got == nil {
+				t.Fatalf("Get = %v, %v", got, err)
+			}
+			tt.check(t, got)
+		})
+	}
+}
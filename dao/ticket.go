@@ -0,0 +1,144 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"ai-agent/model"
+)
+
+var ErrTicketNotFound = errors.New("ticket not found")
+
+// TicketFilter 工单查询条件，空字段表示不过滤
+type TicketFilter struct {
+	UserID string
+	Status model.TicketStatus
+	Offset int
+	Limit  int // 0 表示返回 Offset 之后的全部
+}
+
+// TicketStore 工单存储接口
+type TicketStore interface {
+	Create(ctx context.Context, ticket *model.Ticket) error
+	// Get 获取工单，不存在时返回 nil, nil
+	Get(ctx context.Context, id string) (*model.Ticket, error)
+	// List 按创建时间倒序返回当前页的工单，以及满足条件的工单总数
+	List(ctx context.Context, filter TicketFilter) ([]model.Ticket, int, error)
+	// Update 原子地读取、修改并保存工单，fn 返回错误时不保存
+	Update(ctx context.Context, id string, fn func(ticket *model.Ticket) error) (*model.Ticket, error)
+}
+
+var (
+	_ TicketStore = (*MemoryTicketStore)(nil)
+	_ TicketStore = (*RedisTicketStore)(nil)
+)
+
+// MemoryTicketStore 进程内工单存储
+type MemoryTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string][]byte
+}
+
+func NewMemoryTicketStore() *MemoryTicketStore {
+	return &MemoryTicketStore{tickets: make(map[string][]byte)}
+}
+
+func (s *MemoryTicketStore) Create(ctx context.Context, ticket *model.Ticket) error {
+	if ticket == nil || ticket.ID == "" {
+		return fmt.Errorf("%w: ticket.ID is empty", ErrInvalidParam)
+	}
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickets[ticket.ID] = data
+	return nil
+}
+
+func (s *MemoryTicketStore) Get(ctx context.Context, id string) (*model.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+func (s *MemoryTicketStore) List(ctx context.Context, filter TicketFilter) ([]model.Ticket, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]model.Ticket, 0)
+	for id := range s.tickets {
+		ticket, err := s.load(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if matchTicket(ticket, filter) {
+			result = append(result, *ticket)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+	total := len(result)
+	result = result[min(filter.Offset, total):]
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, total, nil
+}
+
+func (s *MemoryTicketStore) Update(ctx context.Context, id string, fn func(ticket *model.Ticket) error) (*model.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticket, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if ticket == nil {
+		return nil, ErrTicketNotFound
+	}
+	if err := fn(ticket); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return nil, err
+	}
+	s.tickets[id] = data
+	return ticket, nil
+}
+
+// load 读取工单，调用方需持有锁
+func (s *MemoryTicketStore) load(id string) (*model.Ticket, error) {
+	data, ok := s.tickets[id]
+	if !ok {
+		return nil, nil
+	}
+
+	var ticket model.Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// matchTicket 判断工单是否满足查询条件
+func matchTicket(ticket *model.Ticket, filter TicketFilter) bool {
+	if filter.UserID != "" && ticket.UserID != filter.UserID {
+		return false
+	}
+	if filter.Status != "" && ticket.Status != filter.Status {
+		return false
+	}
+	return true
+}
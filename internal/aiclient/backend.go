@@ -0,0 +1,48 @@
+package aiclient
+
+import (
+	"ai-agent/model"
+	"context"
+)
+
+// Backend AI 后端接口，Client 通过 HTTP 调用 Python FastAPI 服务实现该接口
+type Backend interface {
+	Chat(req model.ChatRequest) (*model.ChatResponse, error)
+	// ChatStream 与 Chat 相同，但每生成一段回复就调用 onDelta，返回完整回复
+	// ctx 取消或超过 ai.timeout 时中止读取并返回错误
+	ChatStream(ctx context.Context, req model.ChatRequest, onDelta func(text string)) (*model.ChatResponse, error)
+	RecognizeIntent(req model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error)
+	CheckFlowInterrupt(req model.InterruptCheckRequest) (*model.InterruptCheckResponse, error)
+	// Summarize 将已有摘要和较早的消息压缩为新的会话摘要
+	Summarize(req model.SummarizeRequest) (*model.SummarizeResponse, error)
+	CreateTicket(req model.Ticket) (*model.Ticket, error)
+	CallFlowTool(toolName string, params map[string]string) (string, error)
+
+	CallKnowledgeAdd(req model.KnowledgeRequest) (*model.KnowledgeResponse, error)
+	CallKnowledgeList() (*model.KnowledgeListResponse, error)
+	CallKnowledgeDelete(index string) (*model.KnowledgeResponse, error)
+	CallKnowledgeClear() (*model.KnowledgeResponse, error)
+	CallKnowledgeCount() (*model.KnowledgeResponse, error)
+
+	Close() error
+}
+
+var (
+	_ Backend = (*Client)(nil)
+	_ Backend = (*Fake)(nil)
+)
+
+// streamChunkSize 后端不支持流式时，本地切分回复的块大小（字符数）
+const streamChunkSize = 8
+
+// emitChunks 将完整回复切块后依次回调，作为流式接口的本地替代
+func emitChunks(reply string, onDelta func(text string)) {
+	runes := []rune(reply)
+	for i := 0; i < len(runes); i += streamChunkSize {
+		end := i + streamChunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		onDelta(string(runes[i:end]))
+	}
+}
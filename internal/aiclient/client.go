@@ -4,25 +4,36 @@ import (
 	"ai-agent/model"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	baseURL string
-	httpCli *http.Client
+	httpCli *http.Client // Timeout 限制整个请求，包括读取流式响应
+
+	// streamUnsupported Python 服务未提供 /chat/stream，之后直接调用 /chat
+	streamUnsupported atomic.Bool
 }
 
-func NewClient(baseURL string) *Client {
+func NewClient(baseURL string, timeout time.Duration) *Client {
 	return &Client{
 		baseURL: baseURL,
 		httpCli: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
 		},
 	}
 }
 
+// Close 关闭空闲连接
+func (c *Client) Close() error {
+	c.httpCli.CloseIdleConnections()
+	return nil
+}
+
 func (c *Client) Chat(req model.ChatRequest) (*model.ChatResponse, error) {
 	bs, _ := json.Marshal(req)
 
@@ -111,6 +122,35 @@ func (c *Client) CheckFlowInterrupt(req model.InterruptCheckRequest) (*model.Int
 	return &ir, nil
 }
 
+func (c *Client) Summarize(req model.SummarizeRequest) (*model.SummarizeResponse, error) {
+	bs, _ := json.Marshal(req)
+
+	httpReq, err := http.NewRequest("POST", c.baseURL+"/session/summarize", bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpCli.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("summarize: unexpected status %d", resp.StatusCode)
+	}
+
+	var sr model.SummarizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+	if sr.Summary == "" {
+		return nil, errors.New("empty summary")
+	}
+	return &sr, nil
+}
+
 func (c *Client) CallKnowledgeAdd(req model.KnowledgeRequest) (*model.KnowledgeResponse, error) {
 	bs, _ := json.Marshal(req)
 
@@ -224,6 +264,33 @@ type FlowToolResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// ToolErrorOrderNotFound 工具找不到订单时返回的错误码
+const ToolErrorOrderNotFound = "order_not_found"
+
+// ToolError 工具已执行但报告失败，Code 为工具返回的错误码，可能为空
+type ToolError struct {
+	Tool    string
+	Code    string
+	Message string
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s: %s", e.Tool, e.Message)
+}
+
+// CheckToolResult 检查工具结果中的 error 字段，工具报告失败时返回 *ToolError
+// 结果不是 JSON 对象或没有 error 字段时视为成功
+func CheckToolResult(toolName, result string) error {
+	var payload struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(result), &payload); err != nil || payload.Error == "" {
+		return nil
+	}
+	return &ToolError{Tool: toolName, Code: payload.Code, Message: payload.Error}
+}
+
 func (c *Client) CallFlowTool(toolName string, params map[string]string) (string, error) {
 	req := FlowToolRequest{
 		ToolName:  toolName,
@@ -248,8 +315,12 @@ func (c *Client) CallFlowTool(toolName string, params map[string]string) (string
 		return "", err
 	}
 
+	// 工具报告的失败在 result 中带有错误码，优先返回 *ToolError
+	if err := CheckToolResult(toolName, fr.Result); err != nil {
+		return "", err
+	}
 	if !fr.Success {
-		return "", fmt.Errorf(fr.Error)
+		return "", errors.New(fr.Error)
 	}
 
 	return fr.Result, nil
@@ -0,0 +1,205 @@
+package aiclient
+
+import (
+	"ai-agent/model"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeCall Fake 记录的一次调用
+type FakeCall struct {
+	Method string
+	Args   interface{}
+}
+
+// Fake 可编排的 AI 后端，用于在没有 Python 服务时调试和测试对话
+// 每个方法优先调用对应的 XxxFunc，未设置时返回默认结果；所有调用都记录在 Calls 中
+type Fake struct {
+	ChatFunc               func(req model.ChatRequest) (*model.ChatResponse, error)
+	RecognizeIntentFunc    func(req model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error)
+	CheckFlowInterruptFunc func(req model.InterruptCheckRequest) (*model.InterruptCheckResponse, error)
+	SummarizeFunc          func(req model.SummarizeRequest) (*model.SummarizeResponse, error)
+	CreateTicketFunc       func(req model.Ticket) (*model.Ticket, error)
+	CallFlowToolFunc       func(toolName string, params map[string]string) (string, error)
+
+	// Intents 按顺序返回的意图识别结果，用完后使用 RecognizeIntentFunc 或默认结果
+	Intents []model.IntentRecognitionResponse
+
+	mu        sync.Mutex
+	calls     []FakeCall
+	knowledge []string
+}
+
+// NewFake 创建 Fake 后端
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Calls 返回已记录的调用
+func (f *Fake) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([]FakeCall, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+func (f *Fake) record(method string, args interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, FakeCall{Method: method, Args: args})
+}
+
+func (f *Fake) Chat(req model.ChatRequest) (*model.ChatResponse, error) {
+	f.record("Chat", req)
+	if f.ChatFunc != nil {
+		return f.ChatFunc(req)
+	}
+	return &model.ChatResponse{
+		Reply:     "[fake] " + req.Message,
+		Type:      req.Intent,
+		SessionID: req.SessionID,
+	}, nil
+}
+
+func (f *Fake) ChatStream(ctx context.Context, req model.ChatRequest, onDelta func(text string)) (*model.ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, err := f.Chat(req)
+	if err != nil {
+		return nil, err
+	}
+	emitChunks(resp.Reply, onDelta)
+	return resp, nil
+}
+
+func (f *Fake) RecognizeIntent(req model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error) {
+	f.record("RecognizeIntent", req)
+
+	f.mu.Lock()
+	if len(f.Intents) > 0 {
+		resp := f.Intents[0]
+		f.Intents = f.Intents[1:]
+		f.mu.Unlock()
+		return &resp, nil
+	}
+	f.mu.Unlock()
+
+	if f.RecognizeIntentFunc != nil {
+		return f.RecognizeIntentFunc(req)
+	}
+	return &model.IntentRecognitionResponse{
+		Intent:     model.IntentUnknown,
+		Confidence: 0,
+	}, nil
+}
+
+func (f *Fake) CheckFlowInterrupt(req model.InterruptCheckRequest) (*model.InterruptCheckResponse, error) {
+	f.record("CheckFlowInterrupt", req)
+	if f.CheckFlowInterruptFunc != nil {
+		return f.CheckFlowInterruptFunc(req)
+	}
+	return &model.InterruptCheckResponse{
+		ShouldInterrupt: false,
+		Confidence:      1.0,
+	}, nil
+}
+
+// Summarize 默认把已有摘要和每条消息的开头拼接起来
+func (f *Fake) Summarize(req model.SummarizeRequest) (*model.SummarizeResponse, error) {
+	f.record("Summarize", req)
+	if f.SummarizeFunc != nil {
+		return f.SummarizeFunc(req)
+	}
+
+	parts := make([]string, 0, len(req.Messages)+1)
+	if req.Summary != "" {
+		parts = append(parts, req.Summary)
+	}
+	for _, m := range req.Messages {
+		content := []rune(m.Content)
+		if len(content) > 20 {
+			content = content[:20]
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", m.Role, string(content)))
+	}
+	return &model.SummarizeResponse{Summary: strings.Join(parts, "\n")}, nil
+}
+
+func (f *Fake) CreateTicket(req model.Ticket) (*model.Ticket, error) {
+	f.record("CreateTicket", req)
+	if f.CreateTicketFunc != nil {
+		return f.CreateTicketFunc(req)
+	}
+	return &req, nil
+}
+
+func (f *Fake) CallFlowTool(toolName string, params map[string]string) (string, error) {
+	f.record("CallFlowTool", FlowToolRequest{ToolName: toolName, Arguments: params})
+	if f.CallFlowToolFunc != nil {
+		return f.CallFlowToolFunc(toolName, params)
+	}
+	return fmt.Sprintf(`{"tool":%q,"status":"ok"}`, toolName), nil
+}
+
+func (f *Fake) CallKnowledgeAdd(req model.KnowledgeRequest) (*model.KnowledgeResponse, error) {
+	f.record("CallKnowledgeAdd", req)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.knowledge = append(f.knowledge, req.Texts...)
+	return &model.KnowledgeResponse{Success: true, Count: len(req.Texts), Message: "ok"}, nil
+}
+
+func (f *Fake) CallKnowledgeList() (*model.KnowledgeListResponse, error) {
+	f.record("CallKnowledgeList", nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data := make([]map[string]any, 0, len(f.knowledge))
+	for i, text := range f.knowledge {
+		data = append(data, map[string]any{"index": i, "text": text})
+	}
+	return &model.KnowledgeListResponse{Success: true, Data: data, Total: len(data), Message: "ok"}, nil
+}
+
+func (f *Fake) CallKnowledgeDelete(index string) (*model.KnowledgeResponse, error) {
+	f.record("CallKnowledgeDelete", index)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(f.knowledge) {
+		return &model.KnowledgeResponse{Success: false, Message: "index out of range"}, nil
+	}
+	f.knowledge = append(f.knowledge[:i], f.knowledge[i+1:]...)
+	return &model.KnowledgeResponse{Success: true, Count: 1, Message: "ok"}, nil
+}
+
+func (f *Fake) CallKnowledgeClear() (*model.KnowledgeResponse, error) {
+	f.record("CallKnowledgeClear", nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := len(f.knowledge)
+	f.knowledge = nil
+	return &model.KnowledgeResponse{Success: true, Count: count, Message: "ok"}, nil
+}
+
+func (f *Fake) CallKnowledgeCount() (*model.KnowledgeResponse, error) {
+	f.record("CallKnowledgeCount", nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &model.KnowledgeResponse{Success: true, Count: len(f.knowledge), Message: "ok"}, nil
+}
+
+func (f *Fake) Close() error {
+	f.record("Close", nil)
+	return nil
+}
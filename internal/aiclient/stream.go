@@ -0,0 +1,107 @@
+package aiclient
+
+import (
+	"ai-agent/model"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ChatStream 调用 Python 的 /chat/stream（SSE）逐段获取回复
+// 事件格式：event: delta / data: {"text": "..."}，最后 event: done / data: ChatResponse
+// Python 服务未提供流式接口（404/405）时回退到 /chat，并在本地切块回调，之后不再尝试流式接口
+// 整个请求（包括读取事件流）受 ai.timeout 和 ctx 约束
+func (c *Client) ChatStream(ctx context.Context, req model.ChatRequest, onDelta func(text string)) (*model.ChatResponse, error) {
+	if c.streamUnsupported.Load() {
+		return c.chatChunked(req, onDelta)
+	}
+
+	bs, _ := json.Marshal(req)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/stream", bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpCli.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		log.Printf("[aiclient] /chat/stream 不可用，之后回退到 /chat")
+		c.streamUnsupported.Store(true)
+		return c.chatChunked(req, onDelta)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat stream: unexpected status %d", resp.StatusCode)
+	}
+
+	var (
+		event string
+		reply strings.Builder
+		final *model.ChatResponse
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			switch event {
+			case "done":
+				var cr model.ChatResponse
+				if err := json.Unmarshal([]byte(data), &cr); err != nil {
+					return nil, err
+				}
+				final = &cr
+			case "error":
+				var se model.StreamError
+				_ = json.Unmarshal([]byte(data), &se)
+				return nil, fmt.Errorf("chat stream: %s", se.Error)
+			default:
+				var delta model.StreamDelta
+				if err := json.Unmarshal([]byte(data), &delta); err != nil {
+					return nil, err
+				}
+				reply.WriteString(delta.Text)
+				onDelta(delta.Text)
+			}
+		case line == "":
+			event = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if final == nil {
+		final = &model.ChatResponse{Type: req.Intent, SessionID: req.SessionID}
+	}
+	if final.Reply == "" {
+		final.Reply = reply.String()
+	}
+	return final, nil
+}
+
+// chatChunked 调用 /chat 获取完整回复，再在本地切块回调
+func (c *Client) chatChunked(req model.ChatRequest, onDelta func(text string)) (*model.ChatResponse, error) {
+	cr, err := c.Chat(req)
+	if err != nil {
+		return nil, err
+	}
+	emitChunks(cr.Reply, onDelta)
+	return cr, nil
+}
@@ -1,51 +1,148 @@
 package main
 
 import (
+	"ai-agent/config"
 	"ai-agent/dao"
 	"ai-agent/internal/aiclient"
-	"ai-agent/model"
 	"ai-agent/route"
 	"ai-agent/service"
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gopkg.in/yaml.v3"
-	"time"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
 	r := gin.Default()
 
-	aiClient := aiclient.NewClient("http://127.0.0.1:8000")
+	var aiClient aiclient.Backend
+	switch cfg.AI.Backend {
+	case "fake":
+		aiClient = aiclient.NewFake()
+		log.Printf("使用 Fake AI 后端")
+	default:
+		aiClient = aiclient.NewClient(cfg.AI.BaseURL, cfg.AI.Timeout)
+	}
 
-	intentConfig, err := loadIntentConfig("config/intents.yaml")
+	intentConfig, err := service.LoadIntentConfig(cfg.Intents.Path)
 	if err != nil {
 		log.Fatalf("加载意图配置失败: %v", err)
 	}
 	log.Printf("加载意图配置成功，共 %d 个意图", len(intentConfig.Intents))
 
-	store := dao.NewRedisStore("localhost:6379", "", 0, 24*time.Hour)
-	chatSvc := service.NewChatService(aiClient, store, intentConfig.Intents)
+	flowDefs, err := service.LoadFlowDefinitions(cfg.Intents.FlowsDir)
+	if err != nil {
+		log.Fatalf("加载Flow配置失败: %v", err)
+	}
+	if err := service.Flows.RegisterDefinitions(flowDefs); err != nil {
+		log.Fatalf("注册Flow失败: %v", err)
+	}
+	log.Printf("加载声明式Flow成功，共 %d 个Flow", len(flowDefs))
 
-	route.Register(r, chatSvc)
+	if err := service.ValidateConfig(intentConfig, service.Flows); err != nil {
+		log.Fatalf("配置校验失败:\n%v", err)
+	}
 
-	if err := r.Run(":8080"); err != nil {
-		panic(err)
+	var (
+		store   dao.SessionStore
+		pushBus dao.PushBus
+		tickets dao.TicketStore
+		handoff dao.HandoffQueue
+		locker  dao.SessionLocker
+		replies dao.ReplyCache
+	)
+	switch cfg.Session.Store {
+	case "memory":
+		store = dao.NewMemoryStore(cfg.Session.TTL, cfg.Session.SaveRetries)
+		pushBus = dao.NewMemoryPushBus()
+		tickets = dao.NewMemoryTicketStore()
+		handoff = dao.NewMemoryHandoffQueue()
+		locker = dao.NewMemorySessionLocker()
+		replies = dao.NewMemoryReplyCache(cfg.Session.TTL, cfg.ReplyPendingTimeout())
+		log.Printf("使用内存会话存储")
+	default:
+		redisClient := dao.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+		store = dao.NewRedisStore(redisClient, cfg.Redis.KeyPrefix, cfg.Session.TTL, cfg.Session.SaveRetries)
+		pushBus = dao.NewRedisPushBus(redisClient, cfg.Redis.KeyPrefix)
+		tickets = dao.NewRedisTicketStore(redisClient, cfg.Redis.KeyPrefix)
+		handoff = dao.NewRedisHandoffQueue(redisClient, cfg.Redis.KeyPrefix)
+		locker = dao.NewRedisSessionLocker(redisClient, cfg.Redis.KeyPrefix)
+		replies = dao.NewRedisReplyCache(redisClient, cfg.Redis.KeyPrefix, cfg.Session.TTL, cfg.ReplyPendingTimeout())
 	}
-}
 
-func loadIntentConfig(path string) (*model.IntentConfig, error) {
-	data, err := os.ReadFile(path)
+	var archive dao.Archive
+	switch cfg.Archive.Backend {
+	case "jsonl":
+		archive, err = dao.NewJSONLArchive(cfg.Archive.Path)
+	case "sqlite":
+		archive, err = dao.NewSQLiteArchive(cfg.Archive.Path)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		log.Fatalf("打开会话归档失败: %v", err)
 	}
 
-	var config model.IntentConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	chatSvc := service.NewChatService(aiClient, store, intentConfig, service.ChatOptions{
+		SaveRetries:  cfg.Session.SaveRetries,
+		PushBus:      pushBus,
+		TicketStore:  tickets,
+		HandoffQueue: handoff,
+		FlowIdlePolicy: func(flowID string) service.FlowIdlePolicy {
+			p := cfg.Flows.Policy(flowID)
+			return service.FlowIdlePolicy{Timeout: p.IdleTimeout, Action: service.FlowIdleAction(p.OnIdle)}
+		},
+		Summary:    service.SummaryPolicy{Threshold: cfg.Session.SummaryThreshold, Keep: cfg.Session.SummaryKeep},
+		Archive:    archive,
+		ReplyCache: replies,
+		Locker:     locker,
+		Lock:       service.SessionLockPolicy{TTL: cfg.Session.LockTTL, Wait: cfg.Session.LockWait},
+	})
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	chatSvc.WatchIntentConfig(watchCtx, cfg.Intents.Path, cfg.Intents.ReloadInterval)
+	chatSvc.RunArchiveSweeper(watchCtx, cfg.Archive.SweepInterval)
+
+	route.Register(r, chatSvc, cfg.Server.AllowedOrigins)
+
+	srv := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: r,
 	}
+	go func() {
+		log.Printf("服务启动，监听 %s", cfg.Server.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务启动失败: %v", err)
+		}
+	}()
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+	stop()
 
-	return &config, nil
+	// 先标记未就绪，等待负载均衡摘除流量，再停止接收新连接并等待进行中的请求完成
+	log.Printf("收到退出信号，%s 后停止服务", cfg.Server.DrainDelay)
+	chatSvc.SetReady(false)
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("等待请求完成超时: %v", err)
+	}
+
+	stopWatch()
+	if err := chatSvc.Close(); err != nil {
+		log.Printf("关闭服务资源失败: %v", err)
+	}
+	log.Printf("服务已退出")
 }
@@ -6,6 +6,7 @@ const (
 	IntentFAQ     IntentType = "faq"
 	IntentFlow    IntentType = "flow"
 	IntentUnknown IntentType = "unknown"
+	IntentHandoff IntentType = "handoff" // 人工客服接管中
 )
 
 type IntentDefinition struct {
@@ -17,11 +18,73 @@ type IntentDefinition struct {
 	Keywords []string   `yaml:"keywords"`
 	Examples []string   `yaml:"examples"`
 	NextFlow string     `yaml:"next_flow,omitempty"`
+	// ConfidenceThreshold 该意图的置信度阈值，为 0 时使用全局阈值
+	ConfidenceThreshold float64 `yaml:"confidence_threshold,omitempty"`
 }
 
 type IntentConfig struct {
-	Version string             `yaml:"version"`
-	Intents []IntentDefinition `yaml:"intents"`
+	Version string `yaml:"version"`
+	// ConfidenceThreshold 全局置信度阈值，识别置信度低于阈值时先向用户澄清，为 0 时不澄清
+	ConfidenceThreshold float64            `yaml:"confidence_threshold,omitempty"`
+	Intents             []IntentDefinition `yaml:"intents"`
+}
+
+// IntentVersion 当前生效的意图配置版本，每次热加载 Revision 加一
+type IntentVersion struct {
+	Version  string `json:"version"`
+	Revision int64  `json:"revision"`
+	LoadedAt string `json:"loaded_at"`
+}
+
+// FlowDefinition 声明式 Flow 定义，对应 config/flows/*.yaml
+type FlowDefinition struct {
+	ID    string                        `yaml:"id"`
+	Name  string                        `yaml:"name"`
+	Steps map[string]FlowStepDefinition `yaml:"steps"`
+}
+
+// FlowStepDefinition 声明式 Flow 步骤
+// 收到用户消息时依次执行：capture 保存输入 -> confirm 分支或 action
+type FlowStepDefinition struct {
+	Capture    string                 `yaml:"capture,omitempty"` // 将用户输入保存到 FlowState 的键
+	Slot       *FlowSlotDefinition    `yaml:"slot,omitempty"`    // 保存前校验用户输入，需要同时设置 capture
+	Confirm    *FlowConfirmDefinition `yaml:"confirm,omitempty"`
+	FlowAction `yaml:",inline"`
+}
+
+// FlowSlotDefinition 槽位校验，校验失败时提示重试，多次失败后转工单
+type FlowSlotDefinition struct {
+	Type        string   `yaml:"type"` // order_id / phone / email / contact / choice / text
+	Label       string   `yaml:"label,omitempty"`
+	Options     []string `yaml:"options,omitempty"` // choice 的选项
+	MinLength   int      `yaml:"min_length,omitempty"`
+	MaxLength   int      `yaml:"max_length,omitempty"`
+	Retry       string   `yaml:"retry,omitempty"`
+	MaxAttempts int      `yaml:"max_attempts,omitempty"`
+}
+
+// FlowConfirmDefinition 确认步骤，使用 utils.NormalizeConfirm 判断确认/修改
+type FlowConfirmDefinition struct {
+	OnConfirm FlowAction `yaml:"on_confirm"`
+	OnModify  FlowAction `yaml:"on_modify"`
+	Retry     string     `yaml:"retry"` // 既不是确认也不是修改时的提示，停留在当前步骤
+}
+
+// FlowAction 步骤动作：调用工具（可选）、回复提示、跳转下一步
+type FlowAction struct {
+	Clear  []string            `yaml:"clear,omitempty"` // 需要清空的 FlowState 键
+	Tool   *FlowToolDefinition `yaml:"tool,omitempty"`
+	Prompt string              `yaml:"prompt"`
+	Next   string              `yaml:"next,omitempty"`
+	Done   bool                `yaml:"done,omitempty"`
+}
+
+// FlowToolDefinition 通过 CallFlowTool 调用的工具
+type FlowToolDefinition struct {
+	Name      string            `yaml:"name"`
+	Args      map[string]string `yaml:"args,omitempty"`
+	ResultKey string            `yaml:"result_key,omitempty"` // 工具结果写入 FlowState 的键，默认 tool_result
+	OnError   string            `yaml:"on_error,omitempty"`   // 调用失败时的回复，流程结束
 }
 
 type SessionState string
@@ -31,6 +94,7 @@ const (
 	SessionActive   SessionState = "active"
 	SessionOnFlow   SessionState = "on_flow"
 	SessionComplete SessionState = "complete"
+	SessionHandoff  SessionState = "handoff" // 已转人工，消息不再经过 DecisionLayer
 )
 
 type TicketStatus string
@@ -57,6 +121,18 @@ const (
 	DecisionNewIntent    DecisionType = "new_intent"
 	DecisionRAG          DecisionType = "rag"
 	DecisionTicket       DecisionType = "ticket"
+	DecisionClarify      DecisionType = "clarify"
+	DecisionFlowControl  DecisionType = "flow_control" // 取消、返回上一步、重新开始、转人工等控制指令
+)
+
+// FlowCommand 本地识别的流程控制指令
+type FlowCommand string
+
+const (
+	FlowCommandCancel  FlowCommand = "cancel"
+	FlowCommandBack    FlowCommand = "back"
+	FlowCommandRestart FlowCommand = "restart"
+	FlowCommandHandoff FlowCommand = "handoff"
 )
 
 type InterruptCheckRequest struct {
@@ -65,6 +141,7 @@ type InterruptCheckRequest struct {
 	CurrentStep string                 `json:"current_step"`
 	UserMessage string                 `json:"user_message"`
 	FlowState   map[string]interface{} `json:"flow_state,omitempty"`
+	History     []Message              `json:"history,omitempty"`
 }
 
 type InterruptCheckResponse struct {
@@ -79,6 +156,25 @@ type DecisionResult struct {
 	FlowID     string       `json:"flow_id,omitempty"`
 	Reply      string       `json:"reply,omitempty"`
 	Confidence float64      `json:"confidence"`
+	Candidates []string     `json:"candidates,omitempty"` // 澄清时提供给用户选择的意图ID
+	Query      string       `json:"query,omitempty"`      // 澄清完成后用于继续处理的原始问题
+	Command    FlowCommand  `json:"command,omitempty"`    // DecisionFlowControl 对应的指令
+	// Interrupted 当前 Flow 被新问题打断，执行前需要把 Flow 挂起
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Clarification 等待用户选择的澄清问题
+type Clarification struct {
+	Candidates []string `json:"candidates"` // 候选意图ID，按展示顺序
+	Message    string   `json:"message"`    // 触发澄清的原始问题
+}
+
+// SlotRetry 当前步骤的槽位连续校验失败的次数，只对记录的 Flow、步骤和槽位有效
+type SlotRetry struct {
+	FlowID   string `json:"flow_id"`
+	Step     string `json:"step"`
+	Slot     string `json:"slot"`
+	Attempts int    `json:"attempts"`
 }
 
 type ChatRequest struct {
@@ -88,6 +184,8 @@ type ChatRequest struct {
 	History   []Message  `json:"history,omitempty"`
 	Intent    IntentType `json:"intent,omitempty"`
 	FlowID    string     `json:"flow_id,omitempty"`
+	// MessageID 客户端生成的消息 ID，重试时保持不变，同一会话内重复的消息直接返回原回复
+	MessageID string `json:"message_id,omitempty"`
 }
 
 type ChatResponse struct {
@@ -96,6 +194,48 @@ type ChatResponse struct {
 	Session   SessionState `json:"session_state,omitempty"`
 	SessionID string       `json:"session_id,omitempty"`
 	FlowStep  string       `json:"flow_step,omitempty"`
+	// Suggestions 澄清问题的候选项，前端可渲染为快捷回复
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Source 服务端主动推送的消息来源（ticket/agent/system），机器人回复为空
+	Source string `json:"source,omitempty"`
+}
+
+// PushMessage 推送给会话的服务端消息，例如工单状态更新、人工客服回复
+type PushMessage struct {
+	SessionID string `json:"session_id"`
+	Source    string `json:"source"`
+	Content   string `json:"content"`
+	Timestamp string `json:"timestamp"`
+}
+
+// StreamEventType 流式对话事件类型
+type StreamEventType string
+
+const (
+	StreamEventDecision StreamEventType = "decision"  // 决策结果，data 为 DecisionResult
+	StreamEventFlowStep StreamEventType = "flow_step" // Flow 步骤变化，data 为 StreamFlowStep
+	StreamEventDelta    StreamEventType = "delta"     // 回复增量文本，data 为 StreamDelta
+	StreamEventDone     StreamEventType = "done"      // 本轮结束，data 为完整的 ChatResponse
+	StreamEventError    StreamEventType = "error"     // 处理失败，data 为 StreamError
+)
+
+// StreamEvent 流式对话事件
+type StreamEvent struct {
+	Type StreamEventType
+	Data interface{}
+}
+
+type StreamFlowStep struct {
+	FlowStep string       `json:"flow_step"`
+	Session  SessionState `json:"session_state"`
+}
+
+type StreamDelta struct {
+	Text string `json:"text"`
+}
+
+type StreamError struct {
+	Error string `json:"error"`
 }
 
 type IntentRecognitionRequest struct {
@@ -116,6 +256,7 @@ type Message struct {
 	Role      MessageRole `json:"role"`
 	Content   string      `json:"content"`
 	Timestamp string      `json:"timestamp,omitempty"`
+	AgentID   string      `json:"agent_id,omitempty"` // 人工客服回复时记录坐席 ID
 }
 
 type Session struct {
@@ -126,9 +267,182 @@ type Session struct {
 	FlowID      string                 `json:"flow_id,omitempty"`
 	CurrentStep string                 `json:"current_step,omitempty"`
 	FlowState   map[string]interface{} `json:"flow_state,omitempty"`
-	Version     int64                  `json:"version"` // 版本号，用于乐观锁
-	CreatedAt   string                 `json:"created_at"`
-	UpdatedAt   string                 `json:"updated_at"`
+	// Clarification 上一轮提出的澄清问题，用户回答后清空
+	Clarification *Clarification `json:"clarification,omitempty"`
+	// SlotRetry 槽位校验失败次数，不放在 FlowState 中，避免进入模板、工具参数和步骤快照
+	SlotRetry *SlotRetry `json:"slot_retry,omitempty"`
+	// Handoff 人工客服接管信息，交还机器人后清空
+	Handoff *Handoff `json:"handoff,omitempty"`
+	// StepHistory 当前 Flow 依次进入的步骤，最后一项是当前步骤，用于“返回上一步”
+	StepHistory []FlowStepSnapshot `json:"step_history,omitempty"`
+	// IdleResume Flow 空闲超时后，等待用户选择继续还是处理新问题
+	IdleResume *IdleResume `json:"idle_resume,omitempty"`
+	// SuspendedFlows 被打断后挂起的 Flow，最后一项最先恢复
+	SuspendedFlows []SuspendedFlow `json:"suspended_flows,omitempty"`
+	// ResumeOffered 已提示用户恢复栈顶的 Flow，等待用户回复【继续】
+	ResumeOffered bool `json:"resume_offered,omitempty"`
+	// Summary 早期消息的滚动摘要，调用 AI 时放在历史消息之前
+	Summary *SessionSummary `json:"summary,omitempty"`
+	// ArchivedAt 最近一次归档的时间，之后没有新对话的会话不再重复归档
+	ArchivedAt string `json:"archived_at,omitempty"`
+	Version    int64  `json:"version"` // 最后一个事件的序号，用于乐观锁
+	// Fence 最近一次持有会话锁保存时的租约令牌，令牌更小的写入会被拒绝
+	Fence     int64  `json:"fence,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SessionEventType 会话事件类型
+type SessionEventType string
+
+const (
+	EventMessageAdded   SessionEventType = "message_added"   // 追加一条消息
+	EventFlowStarted    SessionEventType = "flow_started"    // 进入新的 Flow
+	EventFlowEnded      SessionEventType = "flow_ended"      // 离开 Flow，清空 Flow 位置
+	EventStepAdvanced   SessionEventType = "step_advanced"   // 当前 Flow 的步骤或 FlowState 变化
+	EventStateChanged   SessionEventType = "state_changed"   // 会话状态变化
+	EventContextUpdated SessionEventType = "context_updated" // 澄清、转人工、挂起 Flow 等其余字段变化
+	EventSnapshot       SessionEventType = "snapshot"        // 会话文档快照，回放从最近的快照开始，更早的事件可以删除
+)
+
+// SessionEvent 会话事件，Session 文档是从最近的快照开始按 Seq 顺序回放事件的结果
+type SessionEvent struct {
+	Seq       int64                  `json:"seq"`
+	Type      SessionEventType       `json:"type"`
+	At        string                 `json:"at"`
+	Message   *Message               `json:"message,omitempty"`
+	State     SessionState           `json:"state,omitempty"`
+	FlowID    string                 `json:"flow_id,omitempty"`
+	Step      string                 `json:"step,omitempty"`
+	FlowState map[string]interface{} `json:"flow_state,omitempty"`
+	Context   *SessionContext        `json:"context,omitempty"`
+	// Changed context_updated 事件中变化的字段（JSON 字段名），Context 只有这些字段有值
+	Changed  []string `json:"changed,omitempty"`
+	Snapshot *Session `json:"snapshot,omitempty"`
+}
+
+// SessionContext 会话中消息、Flow 位置和状态以外的字段，context_updated 事件只携带变化的字段
+type SessionContext struct {
+	UserID         string             `json:"user_id"`
+	Clarification  *Clarification     `json:"clarification,omitempty"`
+	SlotRetry      *SlotRetry         `json:"slot_retry,omitempty"`
+	Handoff        *Handoff           `json:"handoff,omitempty"`
+	StepHistory    []FlowStepSnapshot `json:"step_history,omitempty"`
+	IdleResume     *IdleResume        `json:"idle_resume,omitempty"`
+	SuspendedFlows []SuspendedFlow    `json:"suspended_flows,omitempty"`
+	ResumeOffered  bool               `json:"resume_offered,omitempty"`
+	Summary        *SessionSummary    `json:"summary,omitempty"`
+	ArchivedAt     string             `json:"archived_at,omitempty"`
+	CreatedAt      string             `json:"created_at"`
+	UpdatedAt      string             `json:"updated_at"`
+}
+
+type SessionEventsResponse struct {
+	SessionID string         `json:"session_id"`
+	Events    []SessionEvent `json:"events"`
+	Count     int            `json:"count"`
+}
+
+// FlowStepSnapshot 进入某个步骤时的快照
+type FlowStepSnapshot struct {
+	Step      string                 `json:"step"`
+	FlowState map[string]interface{} `json:"flow_state,omitempty"` // 进入该步骤时的 FlowState
+	Prompt    string                 `json:"prompt,omitempty"`     // 询问该步骤时的回复，返回时重新展示
+}
+
+// SessionSummary 会话摘要，Until 之前（含）的消息已压缩进 Content
+type SessionSummary struct {
+	Content   string `json:"content"`
+	Until     string `json:"until"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SummarizeRequest 请求 AI 将已有摘要和新消息合并为新的摘要
+type SummarizeRequest struct {
+	SessionID string    `json:"session_id"`
+	Summary   string    `json:"summary,omitempty"`
+	Messages  []Message `json:"messages"`
+}
+
+type SummarizeResponse struct {
+	Summary string `json:"summary"`
+}
+
+// SuspendedFlow 挂起的 Flow，恢复时回到原步骤并保留已填写的数据
+type SuspendedFlow struct {
+	FlowID      string                 `json:"flow_id"`
+	Step        string                 `json:"step"`
+	FlowState   map[string]interface{} `json:"flow_state,omitempty"`
+	StepHistory []FlowStepSnapshot     `json:"step_history,omitempty"`
+	SuspendedAt string                 `json:"suspended_at"`
+}
+
+// IdleResume 空闲超时询问
+type IdleResume struct {
+	Message string `json:"message"` // 触发询问的消息，用户选择新问题时按该消息处理
+	AskedAt string `json:"asked_at"`
+}
+
+// Handoff 人工客服接管信息
+type Handoff struct {
+	Reason      string `json:"reason,omitempty"`
+	AgentID     string `json:"agent_id,omitempty"` // 为空表示等待坐席接入
+	RequestedAt string `json:"requested_at"`
+	ClaimedAt   string `json:"claimed_at,omitempty"`
+	Pending     int    `json:"pending"` // 坐席尚未回复的用户消息数
+}
+
+// HandoffSession 等待人工接入的会话摘要
+type HandoffSession struct {
+	SessionID   string `json:"session_id"`
+	UserID      string `json:"user_id"`
+	Reason      string `json:"reason,omitempty"`
+	RequestedAt string `json:"requested_at"`
+	Pending     int    `json:"pending"`
+	LastMessage string `json:"last_message,omitempty"`
+}
+
+// ArchiveReason 会话归档的触发原因
+type ArchiveReason string
+
+const (
+	ArchiveOnComplete ArchiveReason = "complete" // 会话结束时归档
+	ArchiveOnExpiry   ArchiveReason = "expiry"   // 会话即将过期时由后台任务归档
+)
+
+// ArchivedSession 归档的会话快照，同一个会话可以有多条归档记录
+type ArchivedSession struct {
+	SessionID  string        `json:"session_id"`
+	UserID     string        `json:"user_id"`
+	Reason     ArchiveReason `json:"reason"`
+	ArchivedAt string        `json:"archived_at"`
+	Session    Session       `json:"session"`
+}
+
+// SessionPreview 用户会话列表中的一项，只带最后一条消息
+type SessionPreview struct {
+	SessionID    string       `json:"session_id"`
+	State        SessionState `json:"state"`
+	FlowID       string       `json:"flow_id,omitempty"`
+	MessageCount int          `json:"message_count"`
+	LastMessage  *Message     `json:"last_message,omitempty"`
+	CreatedAt    string       `json:"created_at"`
+	UpdatedAt    string       `json:"updated_at"`
+}
+
+type TicketListResponse struct {
+	Tickets []Ticket `json:"tickets"`
+	Total   int      `json:"total"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+}
+
+type UserSessionsResponse struct {
+	UserID   string           `json:"user_id"`
+	Sessions []SessionPreview `json:"sessions"`
+	Total    int              `json:"total"`
+	Offset   int              `json:"offset"`
+	Limit    int              `json:"limit"`
 }
 
 type SessionHistoryResponse struct {
@@ -138,15 +452,33 @@ type SessionHistoryResponse struct {
 }
 
 type Ticket struct {
-	ID          string       `json:"id"`
-	SessionID   string       `json:"session_id"`
-	UserID      string       `json:"user_id"`
-	Intent      IntentType   `json:"intent"`
-	Subject     string       `json:"subject"`
-	Description string       `json:"description"`
-	Status      TicketStatus `json:"status"`
-	CreatedAt   string       `json:"created_at"`
-	UpdatedAt   string       `json:"updated_at"`
+	ID          string          `json:"id"`
+	SessionID   string          `json:"session_id"`
+	UserID      string          `json:"user_id"`
+	Intent      IntentType      `json:"intent"`
+	Subject     string          `json:"subject"`
+	Description string          `json:"description"`
+	Category    string          `json:"category,omitempty"`
+	Contact     string          `json:"contact,omitempty"`
+	Status      TicketStatus    `json:"status"`
+	Comments    []TicketComment `json:"comments,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// TicketComment 工单备注，状态变更也会记录一条备注
+type TicketComment struct {
+	ID        string `json:"id"`
+	Author    string `json:"author"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// TicketUpdateRequest PATCH /ticket/:id 请求体
+type TicketUpdateRequest struct {
+	Status  TicketStatus `json:"status"`
+	Author  string       `json:"author"`
+	Comment string       `json:"comment,omitempty"`
 }
 
 type KnowledgeRequest struct {
@@ -2,6 +2,7 @@
 API路由处理
 """
 
+import json
 import os
 import uuid
 from datetime import datetime
@@ -11,7 +12,7 @@ os.environ.setdefault("LLM_API_KEY", "sk-c3c62b663de04038b76d2f444efbc979")
 
 from fastapi import APIRouter, HTTPException
 from pydantic import BaseModel
-from models import ChatRequest, ChatResponse, IntentRecognitionRequest, IntentRecognitionResponse, Ticket, InterruptCheckRequest, InterruptCheckResponse
+from models import ChatRequest, ChatResponse, IntentRecognitionRequest, IntentRecognitionResponse, Ticket, InterruptCheckRequest, InterruptCheckResponse, SummarizeRequest, SummarizeResponse
 from services import ChatService, init_intent_vector_service
 from knowledge_store import init_knowledge_store, get_knowledge_store
 
@@ -91,12 +92,29 @@ def check_flow_interrupt_endpoint(request: InterruptCheckRequest):
     return chat_service.check_flow_interrupt(request)
 
 
+@router.post("/session/summarize", response_model=SummarizeResponse)
+def summarize_endpoint(request: SummarizeRequest):
+    """将已有摘要和较早的消息压缩为新的会话摘要"""
+    return SummarizeResponse(summary=chat_service.summarize(request.summary, request.messages))
+
+
 @router.post("/flow/execute-tool", response_model=ExecuteToolResponse)
 def execute_tool_endpoint(request: ExecuteToolRequest):
     """执行工具函数"""
     try:
         import services
         result = services.execute_tool(request.tool_name, request.arguments)
+        # 工具在结果中报告的失败同样视为执行失败，result 保留错误码供调用方区分
+        try:
+            payload = json.loads(result)
+        except ValueError:
+            payload = None
+        if isinstance(payload, dict) and payload.get("error"):
+            return ExecuteToolResponse(
+                success=False,
+                result=result,
+                error=payload["error"]
+            )
         return ExecuteToolResponse(
             success=True,
             result=result
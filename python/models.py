@@ -65,6 +65,19 @@ class InterruptCheckRequest(BaseModel):
     current_step: Optional[str] = None
     user_message: str
     flow_state: Optional[Dict[str, Any]] = None
+    history: Optional[List[Message]] = None
+
+
+class SummarizeRequest(BaseModel):
+    """会话摘要请求模型：将已有摘要和较早的消息合并为新摘要"""
+    session_id: str
+    summary: Optional[str] = None
+    messages: List[Message]
+
+
+class SummarizeResponse(BaseModel):
+    """会话摘要响应模型"""
+    summary: str
 
 
 class InterruptCheckResponse(BaseModel):
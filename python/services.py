@@ -166,7 +166,7 @@ def execute_tool(tool_name: str, arguments: dict) -> str:
             if order:
                 return json.dumps(order, ensure_ascii=False)
             else:
-                return json.dumps({"error": f"未找到订单 {order_id}"}, ensure_ascii=False)
+                return json.dumps({"error": f"未找到订单 {order_id}", "code": "order_not_found"}, ensure_ascii=False)
         
         elif tool_name == "query_logistics":
             logistics_no = arguments.get("logistics_no", "")
@@ -200,6 +200,36 @@ def execute_tool(tool_name: str, arguments: dict) -> str:
             }
             return json.dumps(ticket, ensure_ascii=False)
         
+        elif tool_name == "submit_exchange":
+            import uuid
+            order_id = arguments.get("order_id", "")
+            if order_id not in MOCK_ORDERS:
+                return json.dumps({"error": f"未找到订单 {order_id}", "code": "order_not_found"}, ensure_ascii=False)
+            exchange = {
+                "exchange_id": f"EXC-{uuid.uuid4().hex[:8].upper()}",
+                "order_id": order_id,
+                "item": arguments.get("item", ""),
+                "target": arguments.get("target", ""),
+                "reason": arguments.get("reason", ""),
+                "status": "processing",
+                "message": "换货申请已受理"
+            }
+            return json.dumps(exchange, ensure_ascii=False)
+        
+        elif tool_name == "apply_invoice":
+            import uuid
+            order_id = arguments.get("order_id", "")
+            if order_id not in MOCK_ORDERS:
+                return json.dumps({"error": f"未找到订单 {order_id}", "code": "order_not_found"}, ensure_ascii=False)
+            invoice = {
+                "invoice_id": f"INV-{uuid.uuid4().hex[:8].upper()}",
+                "order_id": order_id,
+                "title": arguments.get("title", ""),
+                "status": "processing",
+                "message": "开票申请已受理，发票将在3个工作日内开具"
+            }
+            return json.dumps(invoice, ensure_ascii=False)
+        
         else:
             return json.dumps({"error": f"未知工具: {tool_name}"}, ensure_ascii=False)
     
@@ -270,6 +300,10 @@ class ChatService:
         """检查是否应该打断当前Flow"""
         return _check_flow_interrupt(self, request)
 
+    def summarize(self, summary: Optional[str], messages: List[Message]) -> str:
+        """将已有摘要和较早的消息压缩为新的会话摘要"""
+        return _summarize(self, summary, messages)
+
     def retrieve_context(self, query: str, top_k: int = 3) -> str:
         """从向量库检索相关上下文"""
         return _retrieve_context(self, query, top_k)
@@ -635,6 +669,11 @@ def _check_flow_interrupt(chat_service: ChatService, request: InterruptCheckRequ
         {"role": "user", "content": f"用户输入: {request.user_message}"}
     ]
 
+    # 历史记录（含会话摘要）帮助判断用户是否在回答当前步骤
+    if request.history:
+        for msg in request.history:
+            messages.insert(-1, {"role": msg.role, "content": msg.content})
+
     try:
         # 调用OpenAI API进行中断判断
         headers = {
@@ -685,6 +724,57 @@ def _check_flow_interrupt(chat_service: ChatService, request: InterruptCheckRequ
         )
 
 
+def _summarize(chat_service: ChatService, summary: Optional[str], messages: List[Message]) -> str:
+    """将已有摘要和较早的消息压缩为新的会话摘要 - 具体实现"""
+    print(f"会话摘要: 已有摘要 {len(summary or '')} 字, 新消息 {len(messages)} 条")
+
+    system_prompt = """
+    你是一个客服对话摘要助手。请将已有摘要和新的对话内容合并为一段新的摘要。
+
+    要求：
+    - 保留用户的诉求、提供过的订单号/联系方式等关键信息、已办理的业务和结果
+    - 省略寒暄和重复内容
+    - 使用第三人称，不超过300字，直接输出摘要正文
+    """
+
+    dialogue = "\n".join(f"{msg.role}: {msg.content}" for msg in messages)
+    user_content = f"已有摘要：{summary or '无'}\n\n新的对话：\n{dialogue}"
+
+    try:
+        headers = {
+            "Content-Type": "application/json",
+            "Authorization": f"Bearer {chat_service.openai_api_key}"
+        }
+
+        data = {
+            "model": chat_service.api_model,
+            "messages": [
+                {"role": "system", "content": system_prompt},
+                {"role": "user", "content": user_content}
+            ],
+            "temperature": 0.2,
+            "max_tokens": 500
+        }
+
+        response = requests.post(
+            f"{chat_service.openai_base_url}/chat/completions",
+            headers=headers,
+            json=data,
+            timeout=config.llm.timeout
+        )
+
+        if response.status_code != 200:
+            raise Exception(f"API请求失败: {response.status_code}, {response.text}")
+
+        result = response.json()
+        return result["choices"][0]["message"]["content"].strip()
+
+    except Exception as e:
+        print(f"会话摘要失败: {str(e)}")
+        # 返回空摘要，Go 端保留原摘要，下次再试
+        return ""
+
+
 def _retrieve_context(chat_service: ChatService, query: str, top_k: int = 3) -> str:
     """从向量库检索相关上下文"""
     try:
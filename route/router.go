@@ -6,14 +6,26 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Register(r *gin.Engine, chatSvc *service.ChatService) {
+// Register 注册路由，wsOrigins 为 WebSocket 允许的浏览器来源
+func Register(r *gin.Engine, chatSvc *service.ChatService, wsOrigins []string) {
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(200, gin.H{"status": "ok", "intent_version": chatSvc.IntentVersion()})
 	})
 
+	r.GET("/ready", func(c *gin.Context) {
+		if err := chatSvc.Ready(c.Request.Context()); err != nil {
+			c.JSON(503, gin.H{"status": "not_ready", "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ready"})
+	})
+
+	r.GET("/ws", api.WebSocketHandler(chatSvc, wsOrigins))
+
 	chatGroup := r.Group("/chat")
 	{
 		chatGroup.POST("", api.ChatHandler(chatSvc))
+		chatGroup.POST("/stream", api.ChatStreamHandler(chatSvc))
 	}
 
 	intentGroup := r.Group("/intent")
@@ -25,12 +37,43 @@ func Register(r *gin.Engine, chatSvc *service.ChatService) {
 	ticketGroup := r.Group("/ticket")
 	{
 		ticketGroup.POST("/create", api.CreateTicketHandler(chatSvc))
+		ticketGroup.GET("/:ticket_id", api.GetTicketHandler(chatSvc))
+		ticketGroup.PATCH("/:ticket_id", api.UpdateTicketHandler(chatSvc))
+		ticketGroup.POST("/:ticket_id/comments", api.AddTicketCommentHandler(chatSvc))
 	}
+	r.GET("/tickets", api.ListTicketsHandler(chatSvc))
 
 	sessionGroup := r.Group("/session")
 	{
 		sessionGroup.GET("/:session_id/history", api.SessionHistoryHandler(chatSvc))
+		sessionGroup.GET("/:session_id/events", api.SessionEventsHandler(chatSvc))
 		sessionGroup.DELETE("/:session_id", api.ClearSessionHandler(chatSvc))
+		sessionGroup.POST("/:session_id/push", api.PushMessageHandler(chatSvc))
+		sessionGroup.POST("/:session_id/handoff", api.RequestHandoffHandler(chatSvc))
+	}
+
+	userGroup := r.Group("/users")
+	{
+		userGroup.GET("/:user_id/sessions", api.ListUserSessionsHandler(chatSvc))
+		userGroup.DELETE("/:user_id/sessions", api.DeleteUserSessionsHandler(chatSvc))
+	}
+
+	archiveGroup := r.Group("/archive")
+	{
+		archiveGroup.GET("/sessions", api.QueryArchiveHandler(chatSvc))
+	}
+
+	agentGroup := r.Group("/agent")
+	{
+		agentGroup.GET("/sessions", api.ListWaitingSessionsHandler(chatSvc))
+		agentGroup.POST("/sessions/:session_id/claim", api.ClaimSessionHandler(chatSvc))
+		agentGroup.POST("/sessions/:session_id/reply", api.AgentReplyHandler(chatSvc))
+		agentGroup.POST("/sessions/:session_id/release", api.ReleaseSessionHandler(chatSvc))
+	}
+
+	adminGroup := r.Group("/admin")
+	{
+		adminGroup.POST("/intents/reload", api.ReloadIntentsHandler(chatSvc))
 	}
 
 	knowledgeGroup := r.Group("/knowledge")
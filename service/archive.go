@@ -0,0 +1,129 @@
+package service
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrArchiveDisabled 未配置归档存储
+var ErrArchiveDisabled = errors.New("archive is disabled")
+
+const (
+	defaultArchiveLimit = 50
+	maxArchiveLimit     = 500
+)
+
+// archiveOnComplete 会话在本轮对话中进入 complete 状态时归档
+// 被打断挂起的 Flow 还可能恢复，此时不算会话结束
+func (s *ChatService) archiveOnComplete(ctx context.Context, session *model.Session, prevState model.SessionState) {
+	if s.archive == nil || session.State != model.SessionComplete || prevState == model.SessionComplete ||
+		len(session.SuspendedFlows) > 0 {
+		return
+	}
+
+	if err := s.archiveSession(ctx, *session, model.ArchiveOnComplete); err != nil {
+		log.Printf("[Session %s] 归档失败: %v", session.ID, err)
+		return
+	}
+
+	session.ArchivedAt = time.Now().Format(time.RFC3339Nano)
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+	}
+}
+
+// archiveSession 追加一条归档记录
+func (s *ChatService) archiveSession(ctx context.Context, session model.Session, reason model.ArchiveReason) error {
+	record := model.ArchivedSession{
+		SessionID:  session.ID,
+		UserID:     session.UserID,
+		Reason:     reason,
+		ArchivedAt: time.Now().Format(time.RFC3339Nano),
+		Session:    session,
+	}
+	if err := s.archive.Append(ctx, record); err != nil {
+		return err
+	}
+	log.Printf("[Session %s] 已归档, reason=%s, 消息数: %d", session.ID, reason, len(session.Messages))
+	return nil
+}
+
+// RunArchiveSweeper 每隔 interval 扫描一次，归档剩余 TTL 不足两个间隔且之后有新对话的会话
+func (s *ChatService) RunArchiveSweeper(ctx context.Context, interval time.Duration) {
+	if s.archive == nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.sweepExpiring(ctx, 2*interval); err != nil {
+					log.Printf("[Archive] 扫描即将过期的会话失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sweepExpiring 归档将在 within 内过期的会话
+// 归档后把 ArchivedAt 写回会话，其他副本或重启后的扫描通过 archivedSince 跳过；写回时保留剩余 TTL，不延长会话生命周期
+func (s *ChatService) sweepExpiring(ctx context.Context, within time.Duration) error {
+	sessions, err := s.store.ListExpiring(ctx, within)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if archivedSince(session) {
+			continue
+		}
+		if err := s.archiveSession(ctx, session, model.ArchiveOnExpiry); err != nil {
+			log.Printf("[Session %s] 归档失败: %v", session.ID, err)
+			continue
+		}
+
+		session.ArchivedAt = time.Now().Format(time.RFC3339Nano)
+		if err := s.store.SaveWithOptimisticLock(dao.WithKeepTTL(ctx), &session, s.saveRetries); err != nil {
+			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// archivedSince 会话归档之后是否没有新的对话
+func archivedSince(session model.Session) bool {
+	if session.ArchivedAt == "" {
+		return false
+	}
+	archivedAt, err1 := time.Parse(time.RFC3339Nano, session.ArchivedAt)
+	updatedAt, err2 := time.Parse(time.RFC3339Nano, session.UpdatedAt)
+	return err1 == nil && err2 == nil && !archivedAt.Before(updatedAt)
+}
+
+// QueryArchive 按会话、用户和归档时间范围查询归档记录
+func (s *ChatService) QueryArchive(ctx context.Context, filter dao.ArchiveFilter) ([]model.ArchivedSession, error) {
+	if s.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultArchiveLimit
+	}
+	if filter.Limit > maxArchiveLimit {
+		filter.Limit = maxArchiveLimit
+	}
+	records, err := s.archive.Query(ctx, filter)
+	if records == nil && err == nil {
+		records = []model.ArchivedSession{}
+	}
+	return records, err
+}
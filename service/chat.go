@@ -4,10 +4,13 @@ import (
 	"ai-agent/dao"
 	"ai-agent/internal/aiclient"
 	"ai-agent/model"
-	"ai-agent/service/flows"
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,34 +18,167 @@ import (
 
 // ChatService 聊天服务结构体
 type ChatService struct {
-	ai            *aiclient.Client
-	store         *dao.RedisStore
+	ai            aiclient.Backend
+	store         dao.SessionStore
+	pushBus       dao.PushBus
+	tickets       dao.TicketStore
+	handoffQueue  dao.HandoffQueue
+	idlePolicy    func(flowID string) FlowIdlePolicy
+	summary       SummaryPolicy
+	archive       dao.Archive
+	replies       dao.ReplyCache
+	locker        dao.SessionLocker
+	lock          SessionLockPolicy
 	decisionLayer *DecisionLayer
+	saveRetries   int
+	ready         atomic.Bool    // 是否可以接收新流量，关闭时置为 false
+	summarizing   sync.Map       // 正在后台刷新摘要的会话 ID
+	background    sync.WaitGroup // 后台任务，关闭存储前等待完成
+
+	intentMu      sync.Mutex // 串行化意图配置热加载
+	intentPath    string
+	intentModTime time.Time
+}
+
+// ChatOptions ChatService 的可选配置
+type ChatOptions struct {
+	SaveRetries  int              // SaveWithOptimisticLock 的重试次数
+	PushBus      dao.PushBus      // 会话消息推送总线，为 nil 时使用进程内总线
+	TicketStore  dao.TicketStore  // 工单存储，为 nil 时使用进程内存储
+	HandoffQueue dao.HandoffQueue // 人工等待队列，为 nil 时使用进程内队列
+	// FlowIdlePolicy 按 FlowID 返回空闲超时策略，为 nil 时 Flow 不会超时
+	FlowIdlePolicy func(flowID string) FlowIdlePolicy
+	// Summary 会话摘要策略，零值表示不做摘要
+	Summary SummaryPolicy
+	// Archive 会话归档存储，为 nil 时不归档
+	Archive dao.Archive
+	// ReplyCache 已处理消息 ID 的回复缓存，为 nil 时使用进程内缓存
+	ReplyCache dao.ReplyCache
+	// Locker 会话锁，为 nil 或 Lock.TTL 为 0 时不加锁
+	Locker dao.SessionLocker
+	Lock   SessionLockPolicy
 }
 
 // NewChatService 创建ChatService实例
-func NewChatService(ai *aiclient.Client, store *dao.RedisStore, intentDefs []model.IntentDefinition) *ChatService {
+func NewChatService(ai aiclient.Backend, store dao.SessionStore, intentConfig *model.IntentConfig, opts ChatOptions) *ChatService {
 	svc := &ChatService{
-		ai:    ai,
-		store: store,
+		ai:           ai,
+		store:        store,
+		saveRetries:  opts.SaveRetries,
+		pushBus:      opts.PushBus,
+		tickets:      opts.TicketStore,
+		handoffQueue: opts.HandoffQueue,
+		idlePolicy:   opts.FlowIdlePolicy,
+		summary:      opts.Summary,
+		archive:      opts.Archive,
+		replies:      opts.ReplyCache,
+		locker:       opts.Locker,
+		lock:         opts.Lock,
 	}
-	svc.decisionLayer = NewDecisionLayer(ai, intentDefs)
-
-	// 设置 AI 客户端到 flows 包
-	flows.SetAIClient(ai)
+	if svc.pushBus == nil {
+		svc.pushBus = dao.NewMemoryPushBus()
+	}
+	if svc.tickets == nil {
+		svc.tickets = dao.NewMemoryTicketStore()
+	}
+	if svc.handoffQueue == nil {
+		svc.handoffQueue = dao.NewMemoryHandoffQueue()
+	}
+	if svc.replies == nil {
+		svc.replies = dao.NewMemoryReplyCache(24*time.Hour, time.Minute) // 与默认配置一致
+	}
+	svc.decisionLayer = NewDecisionLayer(ai, intentConfig)
+	svc.ready.Store(true)
 
 	return svc
 }
 
+// StreamFunc 接收流式对话事件
+type StreamFunc func(event model.StreamEvent)
+
 // HandleMessage 处理用户消息的主入口方法
 // 这是整个聊天服务的核心入口点
 func (s *ChatService) HandleMessage(ctx context.Context, req model.ChatRequest) (*model.ChatResponse, error) {
+	return s.handleMessage(ctx, req, nil)
+}
+
+// HandleMessageStream 与 HandleMessage 相同，但通过 emit 依次推送
+// decision -> flow_step -> delta... -> done 事件；RAG 回复按生成进度推送增量
+func (s *ChatService) HandleMessageStream(ctx context.Context, req model.ChatRequest, emit StreamFunc) (*model.ChatResponse, error) {
+	streamed := false
+	var streamedText strings.Builder
+	resp, err := s.handleMessage(ctx, req, func(event model.StreamEvent) {
+		if delta, ok := event.Data.(model.StreamDelta); ok && event.Type == model.StreamEventDelta {
+			streamed = true
+			streamedText.WriteString(delta.Text)
+		}
+		emit(event)
+	})
+	if err != nil {
+		emit(model.StreamEvent{Type: model.StreamEventError, Data: model.StreamError{Error: err.Error()}})
+		return nil, err
+	}
+
+	if resp.Type == model.IntentFlow {
+		emit(model.StreamEvent{Type: model.StreamEventFlowStep, Data: model.StreamFlowStep{
+			FlowStep: resp.FlowStep,
+			Session:  resp.Session,
+		}})
+	}
+	// Flow、澄清、工单的回复是一次性生成的，整段推送
+	if !streamed && resp.Reply != "" {
+		emit(model.StreamEvent{Type: model.StreamEventDelta, Data: model.StreamDelta{Text: resp.Reply}})
+	}
+	// 流式生成之后追加的内容（例如恢复挂起 Flow 的提示）补发一次增量
+	if rest, ok := strings.CutPrefix(resp.Reply, streamedText.String()); streamed && ok && rest != "" {
+		emit(model.StreamEvent{Type: model.StreamEventDelta, Data: model.StreamDelta{Text: rest}})
+	}
+	emit(model.StreamEvent{Type: model.StreamEventDone, Data: resp})
+
+	return resp, nil
+}
+
+// handleMessage 处理一轮对话，emit 不为 nil 时推送决策事件并流式生成 RAG 回复
+func (s *ChatService) handleMessage(ctx context.Context, req model.ChatRequest, emit StreamFunc) (*model.ChatResponse, error) {
 	// 如果前端没有提供SessionID，自动生成一个（支持无状态客户端）
 	if req.SessionID == "" {
 		req.SessionID = uuid.New().String()
 		log.Printf("[Session %s] 自动生成SessionID", req.SessionID)
 	}
 
+	// 同一会话的消息逐条处理，避免两轮对话基于同一份旧状态各自推进 Flow
+	ctx, unlock, err := s.lockSession(ctx, req.SessionID)
+	if err != nil {
+		log.Printf("[Session %s] 获取会话锁失败: %v", req.SessionID, err)
+		return nil, err
+	}
+	defer unlock()
+
+	// 客户端重试同一条消息时返回原回复，不再重复执行 Flow 步骤
+	// 执行前先占用消息 ID，第一次请求仍在处理时重试也不会再执行一次
+	if req.MessageID != "" {
+		resp, err := s.claimMessage(ctx, req)
+		if err != nil {
+			log.Printf("[Session %s] 占用消息 %s 失败: %v", req.SessionID, req.MessageID, err)
+			return nil, err
+		}
+		if resp != nil {
+			return resp, nil
+		}
+	}
+
+	resp, err := s.handleTurn(ctx, req, emit)
+	if req.MessageID != "" {
+		s.finishMessage(ctx, req, resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// handleTurn 加载会话并执行一轮对话
+func (s *ChatService) handleTurn(ctx context.Context, req model.ChatRequest, emit StreamFunc) (*model.ChatResponse, error) {
 	// 获取或创建会话
 	session, err := s.getOrCreateSession(ctx, req.SessionID, req.UserID)
 	if err != nil {
@@ -50,18 +186,61 @@ func (s *ChatService) HandleMessage(ctx context.Context, req model.ChatRequest)
 		return nil, err
 	}
 
+	// 本轮对话结束时会话进入 complete 状态则归档
+	prevState := session.State
+	defer s.archiveOnComplete(ctx, session, prevState)
+
 	// 记录当前会话状态，便于调试
 	log.Printf("[Session %s] 状态: %s, FlowID: %s, 步骤: %s, 消息数: %d, Version: %d",
 		req.SessionID, session.State, session.FlowID, session.CurrentStep, len(session.Messages), session.Version)
 
+	// 已转人工的会话不经过决策层，消息留给坐席处理
+	if session.State == model.SessionHandoff {
+		return s.handleHandoff(ctx, req, session)
+	}
+
+	// 空闲超时的 Flow 先询问用户继续还是处理新问题
+	if resp, err := s.handleFlowIdle(ctx, &req, session); resp != nil || err != nil {
+		return resp, err
+	}
+
+	// 用户回复【继续】时恢复挂起的 Flow
+	if resp, err := s.handleResumeOffer(ctx, req, session); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// 判断处理流程：Flow模式 or 正常模式
 	decision, err := s.decisionLayer.Decide(ctx, req, session)
 	if err != nil {
 		log.Printf("[Session %s] 决策失败: %v", req.SessionID, err)
 		return nil, err
 	}
+	if emit != nil {
+		emit(model.StreamEvent{Type: model.StreamEventDecision, Data: decision})
+	}
+
+	// 澄清完成后，用原始问题继续处理
+	if decision.Query != "" && decision.Type != model.DecisionClarify {
+		req.Message = decision.Query
+	}
+
+	// 被打断的 Flow 挂起保存，新问题处理完后提示用户恢复
+	if decision.Interrupted && session.State == model.SessionOnFlow && decision.FlowID != session.FlowID {
+		suspendFlow(session)
+	}
+
+	resp, err := s.executeDecision(ctx, req, session, decision, emit)
+	if err != nil {
+		return nil, err
+	}
 
-	// 根据决策执行
+	s.offerResume(ctx, session, resp)
+	s.refreshSummary(ctx, session)
+	return resp, nil
+}
+
+// executeDecision 根据决策执行对应的处理器
+func (s *ChatService) executeDecision(ctx context.Context, req model.ChatRequest, session *model.Session, decision *model.DecisionResult, emit StreamFunc) (*model.ChatResponse, error) {
 	switch decision.Type {
 
 	case model.DecisionContinueFlow:
@@ -72,25 +251,38 @@ func (s *ChatService) HandleMessage(ctx context.Context, req model.ChatRequest)
 		session.State = model.SessionOnFlow
 		session.FlowID = decision.FlowID
 		session.CurrentStep = "start"
+		session.StepHistory = nil
 		return s.handleFlowStateMachine(ctx, req, session)
 
+	case model.DecisionFlowControl:
+		// 取消、返回上一步、重新开始、转人工
+		return s.handleFlowControl(ctx, req, session, decision.Command)
+
 	case model.DecisionRAG:
 		// 走 FAQ / RAG
-		resp, err := s.handleFAQ(ctx, req, s.getRecentHistory(session, 10))
+		resp, err := s.handleFAQ(ctx, req, historyWithSummary(session, recentHistoryCount), emit)
+		if err != nil {
+			log.Printf("[Session %s] FAQ处理失败: %v", session.ID, err)
+			return nil, err
+		}
 		// 记录消息 + 存 session
 		s.addMessage(session, model.RoleUser, req.Message)
 		s.addMessage(session, model.RoleAssistant, resp.Reply)
 		session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
 
-		if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 			log.Printf("[Session %s] FAQ保存失败: %v", session.ID, err)
 		}
 
-		return resp, err
+		return resp, nil
 
 	case model.DecisionTicket:
 		// 创建工单 + 返回提示
-		return s.handleUnknown(ctx, req)
+		return s.handleUnknown(ctx, req, session)
+
+	case model.DecisionClarify:
+		// 置信度不足，记录候选项等待用户选择
+		return s.handleClarify(ctx, req, session, decision)
 	}
 
 	return nil, errors.New("unknown decision")
@@ -148,14 +340,6 @@ func (s *ChatService) addMessage(session *model.Session, role model.MessageRole,
 	}
 }
 
-// getRecentHistory 获取最近的历史消息
-func (s *ChatService) getRecentHistory(session *model.Session, count int) []model.Message {
-	if len(session.Messages) <= count {
-		return session.Messages
-	}
-	return session.Messages[len(session.Messages)-count:]
-}
-
 // GetSessionHistory 获取会话历史
 func (s *ChatService) GetSessionHistory(ctx context.Context, sessionID string) (*model.SessionHistoryResponse, error) {
 	session, err := s.store.Get(ctx, sessionID)
@@ -178,9 +362,29 @@ func (s *ChatService) GetSessionHistory(ctx context.Context, sessionID string) (
 	}, nil
 }
 
+// GetSessionEvents 获取会话的事件日志
+func (s *ChatService) GetSessionEvents(ctx context.Context, sessionID string) (*model.SessionEventsResponse, error) {
+	events, err := s.store.Events(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []model.SessionEvent{}
+	}
+
+	return &model.SessionEventsResponse{
+		SessionID: sessionID,
+		Events:    events,
+		Count:     len(events),
+	}, nil
+}
+
 // ClearSession 清除会话
 func (s *ChatService) ClearSession(ctx context.Context, sessionID string) error {
-	return s.store.Delete(ctx, sessionID)
+	if err := s.store.Delete(ctx, sessionID); err != nil {
+		return err
+	}
+	return s.replies.Delete(ctx, sessionID)
 }
 
 // RecognizeIntent 识别用户意图
@@ -190,11 +394,11 @@ func (s *ChatService) RecognizeIntent(ctx context.Context, req model.IntentRecog
 
 // TypeClassify 类型分类
 func (s *ChatService) TypeClassify(intentID string) *model.DecisionResult {
-	return s.decisionLayer.typeClassify.Classify(intentID)
+	return s.decisionLayer.typeClassify().Classify(intentID)
 }
 
-// handleFAQ 处理FAQ类型的问题
-func (s *ChatService) handleFAQ(ctx context.Context, req model.ChatRequest, history []model.Message) (*model.ChatResponse, error) {
+// handleFAQ 处理FAQ类型的问题，emit 不为 nil 时流式推送回复
+func (s *ChatService) handleFAQ(ctx context.Context, req model.ChatRequest, history []model.Message, emit StreamFunc) (*model.ChatResponse, error) {
 	log.Printf("[handleFAQ] session=%s, history_count=%d", req.SessionID, len(history))
 
 	chatReq := model.ChatRequest{
@@ -205,6 +409,11 @@ func (s *ChatService) handleFAQ(ctx context.Context, req model.ChatRequest, hist
 		Intent:    model.IntentFAQ,
 		FlowID:    "faq_response",
 	}
+	if emit != nil {
+		return s.ai.ChatStream(ctx, chatReq, func(text string) {
+			emit(model.StreamEvent{Type: model.StreamEventDelta, Data: model.StreamDelta{Text: text}})
+		})
+	}
 	return s.ai.Chat(chatReq)
 }
 
@@ -223,8 +432,35 @@ func (s *ChatService) handleFlow(ctx context.Context, req model.ChatRequest, his
 	return s.ai.Chat(chatReq)
 }
 
+// handleClarify 向用户发起澄清问题
+func (s *ChatService) handleClarify(ctx context.Context, req model.ChatRequest, session *model.Session, decision *model.DecisionResult) (*model.ChatResponse, error) {
+	log.Printf("[handleClarify] session=%s, candidates=%v", req.SessionID, decision.Candidates)
+
+	session.Clarification = &model.Clarification{
+		Candidates: decision.Candidates,
+		Message:    decision.Query,
+	}
+	s.addMessage(session, model.RoleUser, req.Message)
+	s.addMessage(session, model.RoleAssistant, decision.Reply)
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 澄清保存失败: %v", session.ID, err)
+		return nil, err
+	}
+
+	return &model.ChatResponse{
+		Reply:       decision.Reply,
+		Type:        model.IntentUnknown,
+		Session:     session.State,
+		SessionID:   session.ID,
+		Suggestions: s.decisionLayer.candidateNames(decision.Candidates),
+	}, nil
+}
+
 // handleUnknown 处理无法识别的意图
-func (s *ChatService) handleUnknown(ctx context.Context, req model.ChatRequest) (*model.ChatResponse, error) {
+// 本轮可能清除了澄清状态，创建工单后同样要保存会话
+func (s *ChatService) handleUnknown(ctx context.Context, req model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
 	log.Printf("[handleUnknown] session=%s, message=%s", req.SessionID, req.Message)
 
 	// 创建工单
@@ -234,36 +470,65 @@ func (s *ChatService) handleUnknown(ctx context.Context, req model.ChatRequest)
 		return nil, err
 	}
 
+	reply := "您好，我无法准确理解您的问题。已为您创建工单，客服人员将尽快与您联系。"
+	s.addMessage(session, model.RoleUser, req.Message)
+	s.addMessage(session, model.RoleAssistant, reply)
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 工单回复保存失败: %v", session.ID, err)
+	}
+
 	return &model.ChatResponse{
-		Reply: "您好，我无法准确理解您的问题。已为您创建工单，客服人员将尽快与您联系。",
+		Reply: reply,
 		Type:  model.IntentUnknown,
 	}, nil
 }
 
-// CreateTicket 创建工单
-func (s *ChatService) CreateTicket(ctx context.Context, userID, sessionID, description string) (*model.Ticket, error) {
-	now := time.Now().Format(time.RFC3339Nano)
-	ticket := model.Ticket{
-		ID:          uuid.New().String(),
-		SessionID:   sessionID,
-		UserID:      userID,
-		Intent:      model.IntentUnknown,
-		Description: description,
-		Status:      model.TicketOpen,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
-
-	log.Printf("[Session %s] 创建工单: %s", sessionID, ticket.ID)
+// Ping 检查服务健康状态
+func (s *ChatService) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
 
-	return s.ai.CreateTicket(ticket)
+// SetReady 设置就绪状态，优雅退出时先置为未就绪
+func (s *ChatService) SetReady(ready bool) {
+	s.ready.Store(ready)
 }
 
-// Ping 检查服务健康状态
-func (s *ChatService) Ping(ctx context.Context) error {
+// Ready 检查服务是否可以接收新流量
+func (s *ChatService) Ready(ctx context.Context) error {
+	if !s.ready.Load() {
+		return errors.New("shutting down")
+	}
 	return s.store.Ping(ctx)
 }
 
+// Close 等待后台任务结束后依次关闭会话存储和 AI 后端，应在 HTTP 服务停止后调用
+func (s *ChatService) Close() error {
+	s.SetReady(false)
+	s.background.Wait()
+
+	var errs []error
+	if err := s.pushBus.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close push bus: %w", err))
+	}
+	if err := s.replies.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close reply cache: %w", err))
+	}
+	if err := s.store.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close store: %w", err))
+	}
+	if s.archive != nil {
+		if err := s.archive.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close archive: %w", err))
+		}
+	}
+	if err := s.ai.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close ai client: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // CallPythonKnowledgeAdd 调用 Python 添加知识
 func (s *ChatService) CallPythonKnowledgeAdd(req model.KnowledgeRequest) (*model.KnowledgeResponse, error) {
 	return s.ai.CallKnowledgeAdd(req)
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-agent/dao"
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+var testIntents = &model.IntentConfig{
+	Intents: []model.IntentDefinition{
+		{ID: "return_goods", Name: "退货", Type: model.IntentFlow, Enabled: true, NextFlow: "return_goods"},
+	},
+}
+
+// startReturnGoods 意图识别结果：启动退货流程
+var startReturnGoods = model.IntentRecognitionResponse{Intent: model.IntentFlow, FlowID: "return_goods", Confidence: 0.95}
+
+func newTestService(t *testing.T, fake *aiclient.Fake, opts ChatOptions) (*ChatService, *dao.MemoryStore) {
+	t.Helper()
+	store := dao.NewMemoryStore(time.Hour, 3)
+	opts.SaveRetries = 3
+	svc := NewChatService(fake, store, testIntents, opts)
+	t.Cleanup(func() { svc.Close() })
+	return svc, store
+}
+
+// send 依次发送消息，返回最后一轮的回复
+func send(t *testing.T, svc *ChatService, sessionID string, messages ...string) *model.ChatResponse {
+	t.Helper()
+	var resp *model.ChatResponse
+	for _, message := range messages {
+		var err error
+		resp, err = svc.HandleMessage(context.Background(), model.ChatRequest{SessionID: sessionID, UserID: "u1", Message: message})
+		if err != nil {
+			t.Fatalf("HandleMessage(%q): %v", message, err)
+		}
+	}
+	return resp
+}
+
+func loadSession(t *testing.T, store *dao.MemoryStore, sessionID string) *model.Session {
+	t.Helper()
+	session, err := store.Get(context.Background(), sessionID)
+	if err != nil || session == nil {
+		t.Fatalf("Get(%s) = %v, %v", sessionID, session, err)
+	}
+	return session
+}
+
+// assertFlowState FlowState 中的值按 fmt.Sprint 比较，want 为 nil 的键必须不存在
+func assertFlowState(t *testing.T, state map[string]interface{}, want map[string]interface{}) {
+	t.Helper()
+	for k, v := range want {
+		got, ok := state[k]
+		if v == nil {
+			if ok {
+				t.Errorf("FlowState[%s] = %v, want absent", k, got)
+			}
+			continue
+		}
+		if !ok || fmt.Sprint(got) != fmt.Sprint(v) {
+			t.Errorf("FlowState[%s] = %v, want %v", k, got, v)
+		}
+	}
+}
+
+func TestFlowSlots(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    []string
+		wantState   model.SessionState
+		wantStep    string
+		wantFlow    map[string]interface{}
+		wantRetry   int // SlotRetry.Attempts，0 表示没有记录
+		wantReply   string
+		wantTickets int
+	}{
+		{
+			name:      "valid order id advances",
+			messages:  []string{"我要退货", "订单号是 12345678"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_reason",
+			wantFlow:  map[string]interface{}{"order_id": "12345678"},
+			wantReply: "订单号 12345678 已记录",
+		},
+		{
+			name:      "invalid order id stays and counts attempts",
+			messages:  []string{"我要退货", "不记得了"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_order_id",
+			wantFlow:  map[string]interface{}{"order_id": nil},
+			wantRetry: 1,
+			wantReply: "未能识别订单号",
+		},
+		{
+			name:      "attempts reset after a valid value",
+			messages:  []string{"我要退货", "不记得了", "12345678"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_reason",
+			wantFlow:  map[string]interface{}{"order_id": "12345678"},
+		},
+		{
+			name:      "choice by number stores the option",
+			messages:  []string{"我要退货", "12345678", "2"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "confirm",
+			wantFlow:  map[string]interface{}{"reason": "收到商品与描述不符"},
+		},
+		{
+			name:      "choice by text stores the option",
+			messages:  []string{"我要退货", "12345678", "尺寸/颜色不合适"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "confirm",
+			wantFlow:  map[string]interface{}{"reason": "尺寸/颜色不合适"},
+		},
+		{
+			name:      "invalid choice lists the options",
+			messages:  []string{"我要退货", "12345678", "5"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_reason",
+			wantRetry: 1,
+			wantReply: "4. 其他原因",
+		},
+		{
+			name:        "escalates to a ticket after max attempts",
+			messages:    []string{"我要退货", "a", "b", "c"},
+			wantState:   model.SessionComplete,
+			wantReply:   "已为您创建工单",
+			wantTickets: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.Intents = []model.IntentRecognitionResponse{startReturnGoods}
+			svc, store := newTestService(t, fake, ChatOptions{})
+
+			resp := send(t, svc, "s1", tt.messages...)
+			if tt.wantReply != "" && !strings.Contains(resp.Reply, tt.wantReply) {
+				t.Errorf("reply = %q, want containing %q", resp.Reply, tt.wantReply)
+			}
+
+			session := loadSession(t, store, "s1")
+			if session.State != tt.wantState || session.CurrentStep != tt.wantStep {
+				t.Errorf("state/step = %s/%s, want %s/%s", session.State, session.CurrentStep, tt.wantState, tt.wantStep)
+			}
+			assertFlowState(t, session.FlowState, tt.wantFlow)
+			for k := range session.FlowState {
+				if strings.HasPrefix(k, "_") {
+					t.Errorf("FlowState has internal key %s", k)
+				}
+			}
+			retry := 0
+			if session.SlotRetry != nil {
+				retry = session.SlotRetry.Attempts
+			}
+			if retry != tt.wantRetry {
+				t.Errorf("SlotRetry = %+v, want %d attempts", session.SlotRetry, tt.wantRetry)
+			}
+
+			tickets, err := svc.ListTickets(context.Background(), TicketQuery{UserID: "u1"})
+			if err != nil {
+				t.Fatalf("ListTickets: %v", err)
+			}
+			if tickets.Total != tt.wantTickets {
+				t.Errorf("tickets = %d, want %d", tickets.Total, tt.wantTickets)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// maxClarifyCandidates 澄清问题最多提供的候选数
+const maxClarifyCandidates = 3
+
+// clarifyIfUncertain 识别置信度低于阈值时生成澄清决策，否则返回 nil
+func (d *DecisionLayer) clarifyIfUncertain(message, intentID, flowID string, confidence float64, suggestions []string) *model.DecisionResult {
+	tc := d.typeClassify()
+	intent := tc.Resolve(intentID, flowID)
+
+	threshold := tc.Threshold(intent)
+	if threshold <= 0 || confidence >= threshold {
+		return nil
+	}
+
+	// 候选来源：识别结果本身 -> Python 建议 -> 本地关键词匹配
+	candidates := make([]string, 0, maxClarifyCandidates)
+	add := func(id string) {
+		if len(candidates) >= maxClarifyCandidates || tc.GetIntentDef(id) == nil {
+			return
+		}
+		for _, c := range candidates {
+			if c == id {
+				return
+			}
+		}
+		candidates = append(candidates, id)
+	}
+
+	if intent != nil {
+		add(intent.ID)
+	}
+	for _, id := range suggestions {
+		add(id)
+	}
+	for _, match := range d.intents.Load().matcher.Match(message) {
+		add(match.Intent.ID)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	log.Printf("[DecisionLayer] 置信度 %.2f 低于阈值 %.2f，发起澄清: %v", confidence, threshold, candidates)
+	return &model.DecisionResult{
+		Type:       model.DecisionClarify,
+		Reply:      d.clarifyQuestion(candidates),
+		Confidence: confidence,
+		Candidates: candidates,
+		Query:      message,
+	}
+}
+
+// clarifyQuestion 生成澄清问题文案
+func (d *DecisionLayer) clarifyQuestion(candidates []string) string {
+	names := d.candidateNames(candidates)
+	if len(names) == 1 {
+		return fmt.Sprintf("请问您是想咨询「%s」吗？回复【是】继续，或直接描述您的问题。", names[0])
+	}
+
+	var b strings.Builder
+	b.WriteString("抱歉，我不太确定您的意思，请问您想咨询的是：\n")
+	for i, name := range names {
+		b.WriteString(fmt.Sprintf("%d. %s\n", i+1, name))
+	}
+	b.WriteString("\n请回复序号，或直接描述您的问题。")
+	return b.String()
+}
+
+// candidateNames 返回候选意图的展示名称
+func (d *DecisionLayer) candidateNames(candidates []string) []string {
+	tc := d.typeClassify()
+	names := make([]string, 0, len(candidates))
+	for _, id := range candidates {
+		name := id
+		if intent := tc.GetIntentDef(id); intent != nil && intent.Name != "" {
+			name = intent.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// resolveClarification 解析用户对澄清问题的回答
+// 无论是否解析成功都会清除会话上的澄清状态；无法解析时返回 nil，按新问题处理
+func (d *DecisionLayer) resolveClarification(req model.ChatRequest, session *model.Session) *model.DecisionResult {
+	clarification := session.Clarification
+	session.Clarification = nil
+
+	intentID := d.pickCandidate(req.Message, clarification.Candidates)
+	if intentID == "" {
+		log.Printf("[DecisionLayer] 澄清回答无法解析，按新问题处理: %s", req.Message)
+		return nil
+	}
+
+	log.Printf("[DecisionLayer] 用户澄清选择: %s", intentID)
+	result := d.typeClassify().Classify(intentID)
+	result.Confidence = 1.0
+	result.Query = clarification.Message
+	return result
+}
+
+// pickCandidate 按序号、名称、意图ID或肯定回答匹配候选项
+func (d *DecisionLayer) pickCandidate(message string, candidates []string) string {
+	answer := utils.NormalizeString(message)
+	if answer == "" {
+		return ""
+	}
+
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n >= 1 && n <= len(candidates) {
+			return candidates[n-1]
+		}
+		return ""
+	}
+
+	if len(candidates) == 1 {
+		switch utils.NormalizeConfirm(answer) {
+		case "confirm", "yes", "y", "是", "是的", "对", "对的", "嗯", "好":
+			return candidates[0]
+		}
+	}
+
+	names := d.candidateNames(candidates)
+	for i, id := range candidates {
+		if answer == id || answer == utils.NormalizeString(names[i]) {
+			return id
+		}
+	}
+	for i, id := range candidates {
+		if strings.Contains(answer, utils.NormalizeString(names[i])) {
+			return id
+		}
+	}
+	return ""
+}
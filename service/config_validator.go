@@ -0,0 +1,71 @@
+package service
+
+import (
+	"ai-agent/model"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadIntentConfig 读取意图配置文件
+func LoadIntentConfig(path string) (*model.IntentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var config model.IntentConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	return &config, nil
+}
+
+// ValidateConfig 校验意图配置与 Flow 注册表是否一致
+// 返回的错误包含所有发现的问题（errors.Join），没有问题时返回 nil
+func ValidateConfig(intentConfig *model.IntentConfig, registry FlowRegistry) error {
+	var errs []error
+
+	if t := intentConfig.ConfidenceThreshold; t < 0 || t > 1 {
+		errs = append(errs, fmt.Errorf("confidence_threshold %.2f 超出范围 [0, 1]", t))
+	}
+
+	seen := make(map[string]bool)
+	for i, intent := range intentConfig.Intents {
+		if intent.ID == "" {
+			errs = append(errs, fmt.Errorf("第 %d 个意图缺少 id", i+1))
+			continue
+		}
+		if seen[intent.ID] {
+			errs = append(errs, fmt.Errorf("意图 %s: id 重复", intent.ID))
+		}
+		seen[intent.ID] = true
+
+		if t := intent.ConfidenceThreshold; t < 0 || t > 1 {
+			errs = append(errs, fmt.Errorf("意图 %s: confidence_threshold %.2f 超出范围 [0, 1]", intent.ID, t))
+		}
+
+		switch intent.Type {
+		case model.IntentFAQ:
+		case model.IntentFlow:
+			if intent.NextFlow == "" {
+				errs = append(errs, fmt.Errorf("意图 %s: flow 类型必须配置 next_flow", intent.ID))
+			} else if _, ok := registry[intent.NextFlow]; !ok && intent.Enabled {
+				errs = append(errs, fmt.Errorf("意图 %s: next_flow %s 未注册", intent.ID, intent.NextFlow))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("意图 %s: 不支持的类型 %q（仅支持 faq/flow）", intent.ID, intent.Type))
+		}
+	}
+
+	for flowID, steps := range registry {
+		if _, ok := steps["start"]; !ok {
+			errs = append(errs, fmt.Errorf("Flow %s: 缺少 start 步骤", flowID))
+		}
+	}
+
+	return errors.Join(errs...)
+}
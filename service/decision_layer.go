@@ -5,19 +5,84 @@ import (
 	"ai-agent/model"
 	"context"
 	"log"
+	"sync/atomic"
+	"time"
 )
 
 type DecisionLayer struct {
-	aiClient     *aiclient.Client
+	aiClient aiclient.Backend
+	intents  atomic.Pointer[intentSnapshot]
+}
+
+// intentSnapshot 一份不可变的意图配置，热加载时整体替换
+type intentSnapshot struct {
 	typeClassify *TypeClassify
+	matcher      *IntentMatcher
+	version      model.IntentVersion
 }
 
+// localFallbackConfidence Python 识别置信度低于该值时，尝试使用本地匹配结果
+const localFallbackConfidence = 0.5
+
 // 创建决策层
-func NewDecisionLayer(aiClient *aiclient.Client, intentDefs []model.IntentDefinition) *DecisionLayer {
-	return &DecisionLayer{
-		aiClient:     aiClient,
-		typeClassify: NewTypeClassify(intentDefs),
+func NewDecisionLayer(aiClient aiclient.Backend, intentConfig *model.IntentConfig) *DecisionLayer {
+	d := &DecisionLayer{
+		aiClient: aiClient,
 	}
+	d.UpdateIntents(intentConfig)
+	return d
+}
+
+// UpdateIntents 原子替换意图配置，进行中的 Decide 调用继续使用旧配置
+func (d *DecisionLayer) UpdateIntents(intentConfig *model.IntentConfig) model.IntentVersion {
+	var revision int64 = 1
+	if old := d.intents.Load(); old != nil {
+		revision = old.version.Revision + 1
+	}
+
+	snapshot := &intentSnapshot{
+		typeClassify: NewTypeClassify(intentConfig),
+		matcher:      NewIntentMatcher(intentConfig.Intents),
+		version: model.IntentVersion{
+			Version:  intentConfig.Version,
+			Revision: revision,
+			LoadedAt: time.Now().Format(time.RFC3339Nano),
+		},
+	}
+	d.intents.Store(snapshot)
+
+	log.Printf("[DecisionLayer] 意图配置已生效: version=%s, revision=%d, 意图数=%d",
+		snapshot.version.Version, revision, len(snapshot.typeClassify.intentDefs))
+	return snapshot.version
+}
+
+// IntentVersion 返回当前生效的意图配置版本
+func (d *DecisionLayer) IntentVersion() model.IntentVersion {
+	return d.intents.Load().version
+}
+
+// typeClassify 返回当前生效的类型分类器
+func (d *DecisionLayer) typeClassify() *TypeClassify {
+	return d.intents.Load().typeClassify
+}
+
+// matchLocally 使用本地关键词匹配器识别意图，没有命中时返回 nil
+func (d *DecisionLayer) matchLocally(message string) *model.DecisionResult {
+	match := d.intents.Load().matcher.Best(message)
+	if match == nil {
+		return nil
+	}
+
+	log.Printf("[DecisionLayer] 本地匹配结果: intent=%s, score=%.2f, confidence=%.2f",
+		match.Intent.ID, match.Score, match.Confidence)
+
+	if result := d.clarifyIfUncertain(message, match.Intent.ID, "", match.Confidence, nil); result != nil {
+		return result
+	}
+
+	result := d.typeClassify().Classify(match.Intent.ID)
+	result.Confidence = match.Confidence
+	return result
 }
 
 // Decide 核心决策方法
@@ -25,11 +90,30 @@ func (d *DecisionLayer) Decide(ctx context.Context, req model.ChatRequest, sessi
 	log.Printf("[DecisionLayer] session=%s, state=%s, current_step=%s",
 		session.ID, session.State, session.CurrentStep)
 
+	// 控制指令在本地识别，不依赖 Python；转人工在任何状态下都可用
+	if cmd := matchFlowCommand(req.Message); cmd == model.FlowCommandHandoff ||
+		(cmd != "" && session.State == model.SessionOnFlow) {
+		log.Printf("[DecisionLayer] 流程控制指令: %s", cmd)
+		return &model.DecisionResult{
+			Type:       model.DecisionFlowControl,
+			FlowID:     session.FlowID,
+			Command:    cmd,
+			Confidence: 1.0,
+		}, nil
+	}
+
 	// 场景1: 已在 Flow 中
 	if session.State == model.SessionOnFlow {
 		return d.handleOnFlow(ctx, req, session)
 	}
 
+	// 上一轮发起了澄清，先解析用户的选择
+	if session.Clarification != nil {
+		if result := d.resolveClarification(req, session); result != nil {
+			return result, nil
+		}
+	}
+
 	// 场景2: 不在 Flow 中
 	return d.handleNotOnFlow(ctx, req, session)
 }
@@ -44,6 +128,7 @@ func (d *DecisionLayer) handleOnFlow(ctx context.Context, req model.ChatRequest,
 		CurrentStep: session.CurrentStep,
 		UserMessage: req.Message,
 		FlowState:   session.FlowState,
+		History:     historyWithSummary(session, recentHistoryCount),
 	}
 
 	resp, err := d.aiClient.CheckFlowInterrupt(checkReq)
@@ -57,7 +142,11 @@ func (d *DecisionLayer) handleOnFlow(ctx context.Context, req model.ChatRequest,
 
 	if resp.ShouldInterrupt {
 		log.Printf("[DecisionLayer] Flow被打断，重新决策 intent=%s", resp.NewIntent)
-		return d.handleNotOnFlow(ctx, req, session)
+		result, err := d.handleNotOnFlow(ctx, req, session)
+		if result != nil {
+			result.Interrupted = true
+		}
+		return result, err
 	}
 
 	log.Printf("[DecisionLayer] 继续当前 Flow")
@@ -75,18 +164,35 @@ func (d *DecisionLayer) handleNotOnFlow(ctx context.Context, req model.ChatReque
 	intentReq := model.IntentRecognitionRequest{
 		SessionID: session.ID,
 		Message:   req.Message,
-		History:   session.Messages,
+		History:   historyWithSummary(session, 0),
 	}
 
 	intentResp, err := d.aiClient.RecognizeIntent(intentReq)
 	if err != nil {
-		log.Printf("[DecisionLayer] RecognizeIntent error: %v", err)
+		log.Printf("[DecisionLayer] RecognizeIntent error: %v, 使用本地匹配", err)
+		if result := d.matchLocally(req.Message); result != nil {
+			return result, nil
+		}
 		return nil, err
 	}
 
 	log.Printf("[DecisionLayer] Intent识别结果: intent=%s, confidence=%.2f, flow_id=%s",
 		intentResp.Intent, intentResp.Confidence, intentResp.FlowID)
 
+	// Python 置信度过低时，本地匹配更有把握则使用本地结果
+	if intentResp.Confidence < localFallbackConfidence {
+		if result := d.matchLocally(req.Message); result != nil && result.Confidence > intentResp.Confidence {
+			log.Printf("[DecisionLayer] Python 置信度过低，使用本地匹配结果")
+			return result, nil
+		}
+	}
+
+	// 置信度低于阈值时先向用户澄清，不直接启动流程或创建工单
+	if result := d.clarifyIfUncertain(req.Message, string(intentResp.Intent), intentResp.FlowID,
+		intentResp.Confidence, intentResp.Suggestions); result != nil {
+		return result, nil
+	}
+
 	// 使用TypeClassify进行类型路由
 	// 当 intent 是 "faq" 类型时，直接走 RAG 流程
 	if intentResp.Intent == "faq" {
@@ -115,7 +221,7 @@ func (d *DecisionLayer) handleNotOnFlow(ctx context.Context, req model.ChatReque
 		}
 	}
 
-	result := d.typeClassify.Classify(string(intentResp.Intent))
+	result := d.typeClassify().Classify(string(intentResp.Intent))
 	result.Confidence = intentResp.Confidence
 	result.Reply = intentResp.Reply
 	if intentResp.FlowID != "" {
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-agent/dao"
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+// decisionIntents 一个流程意图和一个 FAQ 意图，用于本地匹配和澄清
+var decisionIntents = &model.IntentConfig{
+	Version:             "1",
+	ConfidenceThreshold: 0.6,
+	Intents: []model.IntentDefinition{
+		{ID: "return_goods", Name: "退货", Type: model.IntentFlow, Enabled: true, Keywords: []string{"退货"}, NextFlow: "return_goods"},
+		{ID: "faq_shipping", Name: "发货时间", Type: model.IntentFAQ, Enabled: true, Keywords: []string{"发货"}},
+	},
+}
+
+func newDecisionTestService(t *testing.T, fake *aiclient.Fake) (*ChatService, *dao.MemoryStore) {
+	t.Helper()
+	store := dao.NewMemoryStore(time.Hour, 3)
+	svc := NewChatService(fake, store, decisionIntents, ChatOptions{SaveRetries: 3})
+	t.Cleanup(func() { svc.Close() })
+	return svc, store
+}
+
+// countCalls 统计 Fake 上某个方法的调用次数
+func countCalls(fake *aiclient.Fake, method string) int {
+	n := 0
+	for _, call := range fake.Calls() {
+		if call.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLocalFallbackWhenRecognizeFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		wantFlow  string
+		wantReply string
+		wantErr   bool
+	}{
+		{name: "flow keyword starts the flow", message: "我要退货", wantFlow: "return_goods"},
+		{name: "faq keyword answers from rag", message: "什么时候发货", wantReply: "[fake] 什么时候发货"},
+		{name: "no local match returns the error", message: "你好", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.RecognizeIntentFunc = func(model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error) {
+				return nil, errors.New("python unavailable")
+			}
+			svc, store := newDecisionTestService(t, fake)
+
+			resp, err := svc.HandleMessage(context.Background(), model.ChatRequest{SessionID: "s1", UserID: "u1", Message: tt.message})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("HandleMessage = %+v, want error", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HandleMessage: %v", err)
+			}
+			if tt.wantReply != "" && resp.Reply != tt.wantReply {
+				t.Errorf("reply = %q, want %q", resp.Reply, tt.wantReply)
+			}
+			if session := loadSession(t, store, "s1"); session.FlowID != tt.wantFlow {
+				t.Errorf("FlowID = %q, want %q", session.FlowID, tt.wantFlow)
+			}
+		})
+	}
+}
+
+func TestClarificationTurns(t *testing.T) {
+	// Python 置信度低于阈值，候选为识别结果和建议的意图
+	uncertain := model.IntentRecognitionResponse{
+		Intent:      model.IntentFlow,
+		FlowID:      "return_goods",
+		Confidence:  0.55,
+		Suggestions: []string{"faq_shipping"},
+	}
+
+	tests := []struct {
+		name          string
+		answer        string
+		wantFlow      string
+		wantReply     string
+		wantRecognize int
+	}{
+		{name: "pick by number", answer: "1", wantFlow: "return_goods", wantRecognize: 1},
+		{name: "pick by name", answer: "发货时间", wantReply: "[fake] 那个东西", wantRecognize: 1},
+		{name: "unrelated answer is a new question", answer: "算了", wantRecognize: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.Intents = []model.IntentRecognitionResponse{uncertain}
+			svc, store := newDecisionTestService(t, fake)
+
+			resp := send(t, svc, "s1", "那个东西")
+			if !strings.Contains(resp.Reply, "1. 退货") || !strings.Contains(resp.Reply, "2. 发货时间") {
+				t.Fatalf("clarify reply = %q", resp.Reply)
+			}
+			if session := loadSession(t, store, "s1"); session.Clarification == nil {
+				t.Fatal("Clarification not saved")
+			}
+
+			resp = send(t, svc, "s1", tt.answer)
+			if tt.wantReply != "" && resp.Reply != tt.wantReply {
+				t.Errorf("reply = %q, want %q", resp.Reply, tt.wantReply)
+			}
+			session := loadSession(t, store, "s1")
+			if session.Clarification != nil {
+				t.Errorf("Clarification = %+v, want cleared", session.Clarification)
+			}
+			if session.FlowID != tt.wantFlow {
+				t.Errorf("FlowID = %q, want %q", session.FlowID, tt.wantFlow)
+			}
+			if got := countCalls(fake, "RecognizeIntent"); got != tt.wantRecognize {
+				t.Errorf("RecognizeIntent calls = %d, want %d", got, tt.wantRecognize)
+			}
+		})
+	}
+}
+
+func TestReloadIntentConfig(t *testing.T) {
+	fake := aiclient.NewFake()
+	fake.RecognizeIntentFunc = func(model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error) {
+		return nil, errors.New("python unavailable")
+	}
+	svc, store := newDecisionTestService(t, fake)
+
+	path := filepath.Join(t.TempDir(), "intents.yaml")
+	writeIntents := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc.WatchIntentConfig(context.Background(), path, 0)
+
+	// 新配置把“换货”作为退货流程的关键词
+	writeIntents(`version: "2"
+intents:
+  - id: return_goods
+    name: 退货
+    type: flow
+    enabled: true
+    keywords: [换货]
+    next_flow: return_goods
+`)
+	before := svc.IntentVersion()
+	version, err := svc.ReloadIntentConfig()
+	if err != nil {
+		t.Fatalf("ReloadIntentConfig: %v", err)
+	}
+	if version.Version != "2" || version.Revision != before.Revision+1 {
+		t.Errorf("version = %+v, want version 2 revision %d", version, before.Revision+1)
+	}
+
+	send(t, svc, "s1", "我想换货")
+	if session := loadSession(t, store, "s1"); session.FlowID != "return_goods" {
+		t.Errorf("FlowID = %q, want return_goods from the reloaded keywords", session.FlowID)
+	}
+
+	// 校验失败的配置不生效，继续使用上一版
+	writeIntents(`version: "3"
+intents:
+  - id: broken
+    type: flow
+    enabled: true
+    next_flow: no_such_flow
+`)
+	if _, err := svc.ReloadIntentConfig(); err == nil {
+		t.Fatal("ReloadIntentConfig accepted an invalid config")
+	}
+	if got := svc.IntentVersion(); got != *version {
+		t.Errorf("version = %+v, want unchanged %+v", got, *version)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxStepHistory 每个会话最多保留的步骤历史
+const maxStepHistory = 20
+
+// flowCommands 控制指令及其触发词（NormalizeString 之后整句匹配）
+var flowCommands = map[string]model.FlowCommand{
+	"取消":     model.FlowCommandCancel,
+	"取消流程":   model.FlowCommandCancel,
+	"不办了":    model.FlowCommandCancel,
+	"算了":     model.FlowCommandCancel,
+	"退出":     model.FlowCommandCancel,
+	"cancel": model.FlowCommandCancel,
+
+	"返回上一步": model.FlowCommandBack,
+	"上一步":   model.FlowCommandBack,
+	"返回":    model.FlowCommandBack,
+	"back":  model.FlowCommandBack,
+
+	"重新开始":    model.FlowCommandRestart,
+	"从头开始":    model.FlowCommandRestart,
+	"重来":      model.FlowCommandRestart,
+	"restart": model.FlowCommandRestart,
+
+	"转人工":   model.FlowCommandHandoff,
+	"人工":    model.FlowCommandHandoff,
+	"人工客服":  model.FlowCommandHandoff,
+	"转人工客服": model.FlowCommandHandoff,
+	"找人工":   model.FlowCommandHandoff,
+}
+
+// matchFlowCommand 识别控制指令，不是指令时返回空
+func matchFlowCommand(message string) model.FlowCommand {
+	msg := strings.TrimRight(utils.NormalizeString(message), "。.！!")
+	return flowCommands[msg]
+}
+
+// handleFlowControl 执行控制指令，不调用步骤处理器以外的任何远程服务
+func (s *ChatService) handleFlowControl(ctx context.Context, req model.ChatRequest, session *model.Session, cmd model.FlowCommand) (*model.ChatResponse, error) {
+	log.Printf("[Session %s] 执行控制指令: %s, FlowID: %s, 步骤: %s", session.ID, cmd, session.FlowID, session.CurrentStep)
+
+	var reply string
+	switch cmd {
+	case model.FlowCommandHandoff:
+		reply = "正在为您转接人工客服，请稍候。"
+		s.addMessage(session, model.RoleUser, req.Message)
+		s.addMessage(session, model.RoleAssistant, reply)
+		if err := s.startHandoff(ctx, session, "用户要求转人工"); err != nil {
+			log.Printf("[Session %s] 转人工失败: %v", session.ID, err)
+			return nil, err
+		}
+		return &model.ChatResponse{
+			Reply:     reply,
+			Type:      model.IntentHandoff,
+			Session:   session.State,
+			SessionID: session.ID,
+		}, nil
+
+	case model.FlowCommandRestart:
+		// 回到 start 步骤重新执行，与新启动 Flow 相同
+		session.CurrentStep = "start"
+		session.FlowState = make(map[string]interface{})
+		session.StepHistory = nil
+		return s.handleFlowStateMachine(ctx, req, session)
+
+	case model.FlowCommandBack:
+		// 最后一项是当前步骤，弹出后栈顶即为上一步
+		if n := len(session.StepHistory); n > 1 {
+			session.StepHistory = session.StepHistory[:n-1]
+			prev := session.StepHistory[n-2]
+			session.CurrentStep = prev.Step
+			session.FlowState = copyFlowState(prev.FlowState)
+			reply = "好的，已返回上一步。"
+			if prev.Prompt != "" {
+				reply += "\n" + prev.Prompt
+			}
+		} else {
+			reply = "已经是第一步了，请继续填写，或回复【取消】退出当前流程。"
+		}
+
+	default: // model.FlowCommandCancel
+		session.State = model.SessionComplete
+		session.CurrentStep = ""
+		session.FlowState = nil
+		session.StepHistory = nil
+		reply = "已取消当前流程，请问还有什么可以帮您？"
+	}
+
+	s.addMessage(session, model.RoleUser, req.Message)
+	s.addMessage(session, model.RoleAssistant, reply)
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		return nil, err
+	}
+
+	return &model.ChatResponse{
+		Reply:     reply,
+		Type:      model.IntentFlow,
+		Session:   session.State,
+		SessionID: session.ID,
+		FlowStep:  session.CurrentStep,
+	}, nil
+}
+
+// pushStepHistory 记录离开的步骤，超过上限时丢弃最早的记录
+func pushStepHistory(session *model.Session, snapshot model.FlowStepSnapshot) {
+	session.StepHistory = append(session.StepHistory, snapshot)
+	if len(session.StepHistory) > maxStepHistory {
+		session.StepHistory = session.StepHistory[len(session.StepHistory)-maxStepHistory:]
+	}
+}
+
+// copyFlowState 复制 FlowState，避免步骤处理器修改快照
+func copyFlowState(state map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(state))
+	for k, v := range state {
+		cp[k] = v
+	}
+	return cp
+}
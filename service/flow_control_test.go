@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+func TestFlowControl(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  []string
+		wantState model.SessionState
+		wantStep  string
+		wantFlow  map[string]interface{}
+		wantReply string
+		wantHist  int
+	}{
+		{
+			name:      "back restores the previous step and its state",
+			messages:  []string{"我要退货", "12345678", "返回上一步"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_order_id",
+			wantFlow:  map[string]interface{}{"order_id": nil},
+			wantReply: "已返回上一步",
+			wantHist:  1,
+		},
+		{
+			name:      "back twice from confirm",
+			messages:  []string{"我要退货", "12345678", "1", "上一步", "上一步"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_order_id",
+			wantFlow:  map[string]interface{}{"order_id": nil, "reason": nil},
+			wantHist:  1,
+		},
+		{
+			name:      "back at the first step stays",
+			messages:  []string{"我要退货", "返回"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_order_id",
+			wantReply: "已经是第一步了",
+			wantHist:  1,
+		},
+		{
+			name:      "refill after back",
+			messages:  []string{"我要退货", "12345678", "返回上一步", "87654321"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_reason",
+			wantFlow:  map[string]interface{}{"order_id": "87654321"},
+			wantHist:  2,
+		},
+		{
+			name:      "cancel ends the flow",
+			messages:  []string{"我要退货", "12345678", "取消"},
+			wantState: model.SessionComplete,
+			wantReply: "已取消当前流程",
+		},
+		{
+			name:      "restart clears the state",
+			messages:  []string{"我要退货", "12345678", "重新开始"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_order_id",
+			wantFlow:  map[string]interface{}{"order_id": nil},
+			wantHist:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.Intents = []model.IntentRecognitionResponse{startReturnGoods}
+			svc, store := newTestService(t, fake, ChatOptions{})
+
+			resp := send(t, svc, "s1", tt.messages...)
+			if tt.wantReply != "" && !strings.Contains(resp.Reply, tt.wantReply) {
+				t.Errorf("reply = %q, want containing %q", resp.Reply, tt.wantReply)
+			}
+
+			session := loadSession(t, store, "s1")
+			if session.State != tt.wantState || session.CurrentStep != tt.wantStep {
+				t.Errorf("state/step = %s/%s, want %s/%s", session.State, session.CurrentStep, tt.wantState, tt.wantStep)
+			}
+			assertFlowState(t, session.FlowState, tt.wantFlow)
+			if len(session.StepHistory) != tt.wantHist {
+				t.Errorf("step history = %d, want %d", len(session.StepHistory), tt.wantHist)
+			}
+			if tt.wantState == model.SessionComplete && session.FlowState != nil {
+				t.Errorf("FlowState = %v, want nil after cancel", session.FlowState)
+			}
+		})
+	}
+}
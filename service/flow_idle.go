@@ -0,0 +1,160 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// FlowIdleAction Flow 空闲超时后的处理方式
+type FlowIdleAction string
+
+const (
+	FlowIdleAsk    FlowIdleAction = "ask"    // 询问用户继续之前的流程还是处理新问题
+	FlowIdleReset  FlowIdleAction = "reset"  // 结束旧流程，本条消息按新问题处理
+	FlowIdleResume FlowIdleAction = "resume" // 忽略超时，直接继续
+)
+
+// FlowIdlePolicy Flow 空闲超时策略，Timeout 为 0 表示不超时
+type FlowIdlePolicy struct {
+	Timeout time.Duration
+	Action  FlowIdleAction
+}
+
+// handleFlowIdle 处理空闲超时的 Flow 和用户对“继续还是新问题”的回答
+// 返回 nil, nil 表示继续正常决策；用户选择新问题时 req.Message 会被替换为当时的原始问题
+func (s *ChatService) handleFlowIdle(ctx context.Context, req *model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
+	if session.State != model.SessionOnFlow {
+		session.IdleResume = nil
+		return nil, nil
+	}
+
+	if session.IdleResume != nil {
+		return s.resolveIdleResume(ctx, req, session)
+	}
+
+	if !s.flowIdleExpired(session) {
+		return nil, nil
+	}
+
+	policy := s.flowIdlePolicy(session.FlowID)
+	log.Printf("[Session %s] Flow %s 空闲超时, 上次更新: %s, 处理方式: %s",
+		session.ID, session.FlowID, session.UpdatedAt, policy.Action)
+
+	switch policy.Action {
+	case FlowIdleResume:
+		return nil, nil
+
+	case FlowIdleReset:
+		endFlow(session)
+		return nil, nil
+	}
+
+	name := s.decisionLayer.flowName(session.FlowID)
+	session.IdleResume = &model.IdleResume{
+		Message: req.Message,
+		AskedAt: time.Now().Format(time.RFC3339Nano),
+	}
+	session.UpdatedAt = session.IdleResume.AskedAt
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		return nil, err
+	}
+
+	return &model.ChatResponse{
+		Reply:       fmt.Sprintf("继续之前的%s还是新问题？\n1. 继续之前的%s\n2. 新问题", name, name),
+		Type:        model.IntentFlow,
+		Session:     session.State,
+		SessionID:   session.ID,
+		FlowStep:    session.CurrentStep,
+		Suggestions: []string{"继续", "新问题"},
+	}, nil
+}
+
+// resolveIdleResume 解析用户的回答：继续则重新展示当前步骤的提问，否则结束旧流程
+func (s *ChatService) resolveIdleResume(ctx context.Context, req *model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
+	pending := session.IdleResume
+	session.IdleResume = nil
+
+	switch utils.NormalizeConfirm(req.Message) {
+	case "1", "继续", "继续之前的", "confirm", "yes", "y", "是", "好":
+		reply := "好的，我们继续。"
+		if n := len(session.StepHistory); n > 0 && session.StepHistory[n-1].Prompt != "" {
+			reply += "\n" + session.StepHistory[n-1].Prompt
+		}
+
+		s.addMessage(session, model.RoleUser, req.Message)
+		s.addMessage(session, model.RoleAssistant, reply)
+		session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+			return nil, err
+		}
+
+		log.Printf("[Session %s] 用户选择继续 Flow %s, 步骤: %s", session.ID, session.FlowID, session.CurrentStep)
+		return &model.ChatResponse{
+			Reply:     reply,
+			Type:      model.IntentFlow,
+			Session:   session.State,
+			SessionID: session.ID,
+			FlowStep:  session.CurrentStep,
+		}, nil
+
+	case "2", "新问题":
+		// 用询问前的那条消息作为新问题
+		req.Message = pending.Message
+	}
+
+	// 其他回答视为直接提出了新问题
+	log.Printf("[Session %s] 结束空闲的 Flow %s, 按新问题处理: %s", session.ID, session.FlowID, req.Message)
+	endFlow(session)
+	return nil, nil
+}
+
+// flowIdleExpired 判断会话的 Flow 是否已空闲超时
+func (s *ChatService) flowIdleExpired(session *model.Session) bool {
+	policy := s.flowIdlePolicy(session.FlowID)
+	if policy.Timeout <= 0 {
+		return false
+	}
+
+	updatedAt, err := time.Parse(time.RFC3339Nano, session.UpdatedAt)
+	if err != nil {
+		return false
+	}
+	return time.Since(updatedAt) > policy.Timeout
+}
+
+// flowIdlePolicy 返回 Flow 的空闲策略，未配置时不超时
+func (s *ChatService) flowIdlePolicy(flowID string) FlowIdlePolicy {
+	if s.idlePolicy == nil {
+		return FlowIdlePolicy{}
+	}
+	policy := s.idlePolicy(flowID)
+	if policy.Action == "" {
+		policy.Action = FlowIdleAsk
+	}
+	return policy
+}
+
+// endFlow 结束当前 Flow，会话回到非 Flow 状态
+func endFlow(session *model.Session) {
+	session.State = model.SessionComplete
+	session.CurrentStep = ""
+	session.FlowState = nil
+	session.StepHistory = nil
+	session.IdleResume = nil
+}
+
+// flowName 返回 Flow 对应意图的名称，没有配置时返回 FlowID
+func (d *DecisionLayer) flowName(flowID string) string {
+	if intent := d.typeClassify().Resolve("", flowID); intent != nil && intent.Name != "" {
+		return intent.Name
+	}
+	return flowID
+}
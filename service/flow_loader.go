@@ -0,0 +1,146 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/service/flows"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFlowDefinitions 读取目录下所有声明式 Flow 定义（*.yaml / *.yml）
+// 目录不存在时返回空列表，表示只使用 Go 实现的 Flow
+//
+// 示例：
+//
+//	id: invoice
+//	name: 开发票
+//	steps:
+//	  start:
+//	    prompt: 请提供需要开票的订单号。
+//	    next: ask_order_id
+//	  ask_order_id:
+//	    capture: order_id
+//	    slot: {type: order_id, label: 订单号}
+//	    prompt: "订单号 {{order_id}}，回复【确认】提交开票申请，或回复【修改】重新填写。"
+//	    next: confirm
+//	  confirm:
+//	    confirm:
+//	      on_confirm:
+//	        tool: {name: apply_invoice, args: {order_id: "{{order_id}}"}}
+//	        prompt: "开票申请已提交，申请编号 {{tool_result.invoice_id}}"
+//	        done: true
+//	      on_modify:
+//	        clear: [order_id]
+//	        prompt: 好的，请重新提供订单号。
+//	        next: ask_order_id
+//	      retry: 请回复【确认】或【修改】。
+func LoadFlowDefinitions(dir string) ([]model.FlowDefinition, error) {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	defs := make([]model.FlowDefinition, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取Flow文件失败 %s: %w", file, err)
+		}
+
+		var def model.FlowDefinition
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("解析Flow文件失败 %s: %w", file, err)
+		}
+		if err := checkFlowDefinition(def); err != nil {
+			return nil, fmt.Errorf("Flow文件 %s 无效: %w", file, err)
+		}
+
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+// RegisterDefinitions 将声明式 Flow 编译为步骤处理器并注册，与 Go 实现的 Flow 共存
+func (r FlowRegistry) RegisterDefinitions(defs []model.FlowDefinition) error {
+	for _, def := range defs {
+		if _, exists := r[def.ID]; exists {
+			return fmt.Errorf("Flow %s 已注册，不能重复定义", def.ID)
+		}
+	}
+
+	for _, def := range defs {
+		steps := make(map[string]FlowStepHandler, len(def.Steps))
+		for stepID, stepDef := range def.Steps {
+			steps[stepID] = flows.NewDeclarativeStep(def.ID, stepID, stepDef)
+		}
+		r[def.ID] = steps
+	}
+
+	return nil
+}
+
+// checkFlowDefinition 检查声明式 Flow 的步骤和跳转是否完整
+func checkFlowDefinition(def model.FlowDefinition) error {
+	if def.ID == "" {
+		return errors.New("id 不能为空")
+	}
+	if _, ok := def.Steps["start"]; !ok {
+		return errors.New("缺少 start 步骤")
+	}
+
+	checkAction := func(stepID string, action model.FlowAction) error {
+		if action.Next != "" {
+			if _, ok := def.Steps[action.Next]; !ok {
+				return fmt.Errorf("步骤 %s 跳转到不存在的步骤 %s", stepID, action.Next)
+			}
+		}
+		if action.Tool != nil && action.Tool.Name == "" {
+			return fmt.Errorf("步骤 %s 的 tool.name 不能为空", stepID)
+		}
+		return nil
+	}
+
+	for stepID, step := range def.Steps {
+		if step.Slot != nil {
+			if step.Capture == "" {
+				return fmt.Errorf("步骤 %s 设置了 slot 但缺少 capture", stepID)
+			}
+			if !flows.ValidSlotType(flows.SlotType(step.Slot.Type)) {
+				return fmt.Errorf("步骤 %s 的 slot.type 无效: %s", stepID, step.Slot.Type)
+			}
+			if step.Slot.Type == string(flows.SlotChoice) && len(step.Slot.Options) == 0 {
+				return fmt.Errorf("步骤 %s 的 choice 槽位缺少 options", stepID)
+			}
+		}
+
+		if step.Confirm == nil {
+			if err := checkAction(stepID, step.FlowAction); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := checkAction(stepID, step.Confirm.OnConfirm); err != nil {
+			return err
+		}
+		if err := checkAction(stepID, step.Confirm.OnModify); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
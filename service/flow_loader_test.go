@@ -0,0 +1,188 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-agent/model"
+)
+
+func TestLoadFlowDefinitionsExamples(t *testing.T) {
+	defs, err := LoadFlowDefinitions(filepath.Join("..", "config", "flows"))
+	if err != nil {
+		t.Fatalf("LoadFlowDefinitions: %v", err)
+	}
+	if len(defs) == 0 {
+		t.Fatal("config/flows 下没有示例 Flow")
+	}
+
+	registry := FlowRegistry{}
+	if err := registry.RegisterDefinitions(defs); err != nil {
+		t.Fatalf("RegisterDefinitions: %v", err)
+	}
+	for _, def := range defs {
+		if _, exists := Flows[def.ID]; exists {
+			t.Errorf("示例 Flow %s 与 Go 实现的 Flow 重名", def.ID)
+		}
+		for stepID := range def.Steps {
+			if registry[def.ID][stepID] == nil {
+				t.Errorf("Flow %s 步骤 %s 未注册", def.ID, stepID)
+			}
+		}
+	}
+}
+
+func TestLoadFlowDefinitions(t *testing.T) {
+	valid := "id: a\nsteps:\n  start:\n    prompt: hi\n    done: true\n"
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantIDs []string
+		wantErr string
+	}{
+		{
+			name:    "yaml and yml sorted by file name",
+			files:   map[string]string{"b.yml": strings.Replace(valid, "id: a", "id: b", 1), "a.yaml": valid, "note.txt": "ignored"},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:    "invalid yaml",
+			files:   map[string]string{"a.yaml": "id: [a"},
+			wantErr: "解析Flow文件失败",
+		},
+		{
+			name:    "invalid definition",
+			files:   map[string]string{"a.yaml": "id: a\nsteps: {}\n"},
+			wantErr: "缺少 start 步骤",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			defs, err := LoadFlowDefinitions(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFlowDefinitions: %v", err)
+			}
+			var ids []string
+			for _, def := range defs {
+				ids = append(ids, def.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	t.Run("missing dir", func(t *testing.T) {
+		defs, err := LoadFlowDefinitions(filepath.Join(t.TempDir(), "none"))
+		if err != nil || len(defs) != 0 {
+			t.Fatalf("LoadFlowDefinitions = %v, %v, want empty", defs, err)
+		}
+	})
+}
+
+func TestCheckFlowDefinition(t *testing.T) {
+	start := model.FlowStepDefinition{FlowAction: model.FlowAction{Prompt: "hi", Next: "ask"}}
+
+	tests := []struct {
+		name    string
+		def     model.FlowDefinition
+		wantErr string
+	}{
+		{
+			name: "valid",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": start,
+				"ask": {
+					Capture: "order_id",
+					Slot:    &model.FlowSlotDefinition{Type: "order_id"},
+					Confirm: &model.FlowConfirmDefinition{
+						OnConfirm: model.FlowAction{Tool: &model.FlowToolDefinition{Name: "t"}, Done: true},
+						OnModify:  model.FlowAction{Next: "ask"},
+					},
+				},
+			}},
+		},
+		{
+			name:    "missing id",
+			def:     model.FlowDefinition{Steps: map[string]model.FlowStepDefinition{"start": {}}},
+			wantErr: "id 不能为空",
+		},
+		{
+			name:    "missing start",
+			def:     model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{"ask": {}}},
+			wantErr: "缺少 start 步骤",
+		},
+		{
+			name:    "unknown next",
+			def:     model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{"start": start}},
+			wantErr: "跳转到不存在的步骤 ask",
+		},
+		{
+			name: "unknown next in confirm branch",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": {Confirm: &model.FlowConfirmDefinition{OnModify: model.FlowAction{Next: "gone"}}},
+			}},
+			wantErr: "跳转到不存在的步骤 gone",
+		},
+		{
+			name: "tool without name",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": {FlowAction: model.FlowAction{Tool: &model.FlowToolDefinition{}}},
+			}},
+			wantErr: "tool.name 不能为空",
+		},
+		{
+			name: "slot without capture",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": {Slot: &model.FlowSlotDefinition{Type: "text"}},
+			}},
+			wantErr: "缺少 capture",
+		},
+		{
+			name: "unknown slot type",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": {Capture: "x", Slot: &model.FlowSlotDefinition{Type: "date"}},
+			}},
+			wantErr: "slot.type 无效",
+		},
+		{
+			name: "choice without options",
+			def: model.FlowDefinition{ID: "f", Steps: map[string]model.FlowStepDefinition{
+				"start": {Capture: "x", Slot: &model.FlowSlotDefinition{Type: "choice"}},
+			}},
+			wantErr: "缺少 options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFlowDefinition(tt.def)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkFlowDefinition: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
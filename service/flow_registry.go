@@ -29,6 +29,15 @@ var Flows = FlowRegistry{
 		"confirm":      flows.HandleReturnGoodsConfirm,
 		"processing":   flows.HandleReturnGoodsProcessing,
 	},
+	// 换货流程
+	"exchange": {
+		"start":        flows.HandleExchangeStart,
+		"ask_order_id": flows.HandleExchangeAskOrderID,
+		"ask_item":     flows.HandleExchangeAskItem,
+		"ask_target":   flows.HandleExchangeAskTarget,
+		"ask_reason":   flows.HandleExchangeAskReason,
+		"confirm":      flows.HandleExchangeConfirm,
+	},
 	// 订单查询流程
 	"order_query": {
 		"start":      flows.HandleOrderQueryStart,
@@ -51,6 +60,10 @@ var Flows = FlowRegistry{
 // handleFlowStateMachine 状态机处理器
 // 核心逻辑：从Session中获取当前步骤，调用对应的处理器，更新状态
 func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
+	// 步骤处理器通过 ctx 获取 AI 后端和工单服务
+	ctx = flows.WithAIClient(ctx, s.ai)
+	ctx = flows.WithTicketSubmitter(ctx, s)
+
 	// 如果会话状态是 completed，提示用户重新开始
 	if session.State == model.SessionComplete {
 		log.Printf("[Session %s] 会话已完成，请用户重新开始", session.ID)
@@ -59,7 +72,7 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 		session.CurrentStep = ""
 		session.FlowState = nil
 
-		if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
 		}
 
@@ -82,7 +95,7 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 		session.CurrentStep = ""
 		session.FlowState = nil
 
-		if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
 		}
 
@@ -123,7 +136,7 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 
 			session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
 
-			if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+			if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 				log.Printf("[Session %s] 保存失败: %v", session.ID, err)
 			}
 
@@ -142,7 +155,7 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 		session.CurrentStep = ""
 		session.FlowState = nil
 
-		if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
 		}
 
@@ -166,13 +179,27 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 	s.addMessage(session, model.RoleUser, req.Message)
 	s.addMessage(session, model.RoleAssistant, reply)
 
+	// 离开当前步骤后，槽位失败次数不再有效
+	if done || nextStep != currentStep {
+		session.SlotRetry = nil
+	}
+
 	// 更新会话状态
 	if done {
 		session.State = model.SessionComplete
 		session.CurrentStep = ""
 		session.FlowState = nil
+		session.StepHistory = nil
 		log.Printf("[Session %s] Flow完成: %s", session.ID, session.FlowID)
 	} else {
+		// 进入新步骤时记下当时的 FlowState 和提问，供“返回上一步”使用
+		if nextStep != currentStep {
+			pushStepHistory(session, model.FlowStepSnapshot{
+				Step:      nextStep,
+				FlowState: copyFlowState(session.FlowState),
+				Prompt:    reply,
+			})
+		}
 		session.CurrentStep = nextStep
 		log.Printf("[Session %s] 步骤完成，下一步: %s", session.ID, nextStep)
 	}
@@ -180,7 +207,7 @@ func (s *ChatService) handleFlowStateMachine(ctx context.Context, req model.Chat
 	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
 
 	// 使用乐观锁保存会话
-	if err := s.store.SaveWithOptimisticLock(ctx, session, 3); err != nil {
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
 		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
 		return nil, err
 	}
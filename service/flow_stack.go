@@ -0,0 +1,152 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxSuspendedFlows 每个会话最多挂起的 Flow 数，超出时丢弃最早挂起的
+const maxSuspendedFlows = 3
+
+// suspendFlow 将当前 Flow 压栈，会话回到非 Flow 状态
+func suspendFlow(session *model.Session) {
+	log.Printf("[Session %s] 挂起 Flow %s, 步骤: %s", session.ID, session.FlowID, session.CurrentStep)
+
+	session.SuspendedFlows = append(session.SuspendedFlows, model.SuspendedFlow{
+		FlowID:      session.FlowID,
+		Step:        session.CurrentStep,
+		FlowState:   copyFlowState(session.FlowState),
+		StepHistory: session.StepHistory,
+		SuspendedAt: time.Now().Format(time.RFC3339Nano),
+	})
+	if len(session.SuspendedFlows) > maxSuspendedFlows {
+		session.SuspendedFlows = session.SuspendedFlows[len(session.SuspendedFlows)-maxSuspendedFlows:]
+	}
+	session.ResumeOffered = false
+
+	endFlow(session)
+}
+
+// offerResume 打断的问题处理完后，在回复末尾提示用户可以恢复栈顶的 Flow
+func (s *ChatService) offerResume(ctx context.Context, session *model.Session, resp *model.ChatResponse) {
+	// 进入了新的 Flow，等它结束后再重新提示
+	if session.State == model.SessionOnFlow && session.ResumeOffered {
+		session.ResumeOffered = false
+		if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+			log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		}
+		return
+	}
+	if resp == nil || session.ResumeOffered || session.Clarification != nil ||
+		session.State == model.SessionOnFlow || session.State == model.SessionHandoff {
+		return
+	}
+
+	s.dropExpiredSuspended(session)
+	if len(session.SuspendedFlows) == 0 {
+		return
+	}
+
+	top := session.SuspendedFlows[len(session.SuspendedFlows)-1]
+	offer := fmt.Sprintf("您之前的%s还没有完成，回复【继续】可以接着办理。", s.decisionLayer.flowName(top.FlowID))
+
+	// 同步修改已记录的助手回复，保持历史与返回给用户的内容一致
+	if n := len(session.Messages); n > 0 && session.Messages[n-1].Role == model.RoleAssistant &&
+		session.Messages[n-1].Content == resp.Reply {
+		session.Messages[n-1].Content += "\n\n" + offer
+	}
+	if resp.Reply != "" {
+		resp.Reply += "\n\n"
+	}
+	resp.Reply += offer
+	resp.Suggestions = append(resp.Suggestions, "继续")
+	session.ResumeOffered = true
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+	}
+}
+
+// handleResumeOffer 处理用户对恢复提示的回答：继续则恢复栈顶 Flow，拒绝则丢弃
+// 其他回答按新问题处理，提示保持有效，用户稍后仍可回复【继续】
+func (s *ChatService) handleResumeOffer(ctx context.Context, req model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
+	if !session.ResumeOffered || session.State == model.SessionOnFlow {
+		return nil, nil
+	}
+
+	s.dropExpiredSuspended(session)
+	if len(session.SuspendedFlows) == 0 {
+		session.ResumeOffered = false
+		return nil, nil
+	}
+
+	n := len(session.SuspendedFlows)
+	top := session.SuspendedFlows[n-1]
+	name := s.decisionLayer.flowName(top.FlowID)
+
+	var reply string
+	switch utils.NormalizeConfirm(req.Message) {
+	case "继续", "继续办理", "confirm", "yes", "y", "是", "好", "好的":
+		session.SuspendedFlows = session.SuspendedFlows[:n-1]
+		session.State = model.SessionOnFlow
+		session.FlowID = top.FlowID
+		session.CurrentStep = top.Step
+		session.FlowState = copyFlowState(top.FlowState)
+		session.StepHistory = top.StepHistory
+
+		reply = fmt.Sprintf("好的，我们继续之前的%s。", name)
+		if m := len(top.StepHistory); m > 0 && top.StepHistory[m-1].Prompt != "" {
+			reply += "\n" + top.StepHistory[m-1].Prompt
+		}
+		log.Printf("[Session %s] 恢复 Flow %s, 步骤: %s", session.ID, top.FlowID, top.Step)
+
+	case "不用了", "不用", "不继续", "no", "n":
+		session.SuspendedFlows = session.SuspendedFlows[:n-1]
+		reply = fmt.Sprintf("好的，已取消之前的%s。", name)
+		log.Printf("[Session %s] 放弃挂起的 Flow %s", session.ID, top.FlowID)
+
+	default:
+		return nil, nil
+	}
+
+	session.ResumeOffered = false
+	s.addMessage(session, model.RoleUser, req.Message)
+	s.addMessage(session, model.RoleAssistant, reply)
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		return nil, err
+	}
+
+	resp := &model.ChatResponse{
+		Reply:     reply,
+		Type:      model.IntentFlow,
+		Session:   session.State,
+		SessionID: session.ID,
+		FlowStep:  session.CurrentStep,
+	}
+	if session.State != model.SessionOnFlow {
+		resp.Type = model.IntentUnknown
+	}
+	return resp, nil
+}
+
+// dropExpiredSuspended 丢弃挂起时间超过对应 Flow 空闲超时的记录
+func (s *ChatService) dropExpiredSuspended(session *model.Session) {
+	kept := session.SuspendedFlows[:0]
+	for _, sf := range session.SuspendedFlows {
+		policy := s.flowIdlePolicy(sf.FlowID)
+		suspendedAt, err := time.Parse(time.RFC3339Nano, sf.SuspendedAt)
+		if policy.Timeout > 0 && err == nil && time.Since(suspendedAt) > policy.Timeout {
+			log.Printf("[Session %s] 挂起的 Flow %s 已过期", session.ID, sf.FlowID)
+			continue
+		}
+		kept = append(kept, sf)
+	}
+	session.SuspendedFlows = kept
+}
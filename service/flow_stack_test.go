@@ -0,0 +1,91 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+func TestResumeSuspendedFlow(t *testing.T) {
+	const question = "运费怎么算"
+
+	tests := []struct {
+		name          string
+		answer        []string
+		wantState     model.SessionState
+		wantStep      string
+		wantFlow      map[string]interface{}
+		wantReply     string
+		wantSuspended int
+	}{
+		{
+			name:      "continue restores step and state",
+			answer:    []string{"继续"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "ask_reason",
+			wantFlow:  map[string]interface{}{"order_id": "12345678"},
+			wantReply: "继续之前的退货",
+		},
+		{
+			name:      "resumed flow keeps going",
+			answer:    []string{"继续", "1"},
+			wantState: model.SessionOnFlow,
+			wantStep:  "confirm",
+			wantFlow:  map[string]interface{}{"order_id": "12345678", "reason": "商品质量问题"},
+		},
+		{
+			name:      "decline drops the suspended flow",
+			answer:    []string{"不用了"},
+			wantState: model.SessionComplete,
+			wantReply: "已取消之前的退货",
+		},
+		{
+			name:          "another question keeps the offer",
+			answer:        []string{"运费谁出"},
+			wantState:     model.SessionComplete,
+			wantSuspended: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.Intents = []model.IntentRecognitionResponse{startReturnGoods}
+			fake.RecognizeIntentFunc = func(req model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error) {
+				return &model.IntentRecognitionResponse{Intent: "faq", Confidence: 0.95}, nil
+			}
+			fake.CheckFlowInterruptFunc = func(req model.InterruptCheckRequest) (*model.InterruptCheckResponse, error) {
+				return &model.InterruptCheckResponse{ShouldInterrupt: req.UserMessage == question, Confidence: 0.9}, nil
+			}
+			svc, store := newTestService(t, fake, ChatOptions{})
+
+			resp := send(t, svc, "s1", "我要退货", "12345678", question)
+			if !strings.Contains(resp.Reply, "[fake] "+question) || !strings.Contains(resp.Reply, "回复【继续】") {
+				t.Fatalf("interrupt reply = %q, want the answer and a resume offer", resp.Reply)
+			}
+			session := loadSession(t, store, "s1")
+			if n := len(session.SuspendedFlows); n != 1 || session.SuspendedFlows[0].Step != "ask_reason" {
+				t.Fatalf("suspended = %+v, want return_goods at ask_reason", session.SuspendedFlows)
+			}
+			if session.State == model.SessionOnFlow || !session.ResumeOffered {
+				t.Fatalf("state = %s, resume offered = %v", session.State, session.ResumeOffered)
+			}
+
+			resp = send(t, svc, "s1", tt.answer...)
+			if tt.wantReply != "" && !strings.Contains(resp.Reply, tt.wantReply) {
+				t.Errorf("reply = %q, want containing %q", resp.Reply, tt.wantReply)
+			}
+
+			session = loadSession(t, store, "s1")
+			if session.State != tt.wantState || session.CurrentStep != tt.wantStep {
+				t.Errorf("state/step = %s/%s, want %s/%s", session.State, session.CurrentStep, tt.wantState, tt.wantStep)
+			}
+			assertFlowState(t, session.FlowState, tt.wantFlow)
+			if len(session.SuspendedFlows) != tt.wantSuspended {
+				t.Errorf("suspended = %d, want %d", len(session.SuspendedFlows), tt.wantSuspended)
+			}
+		})
+	}
+}
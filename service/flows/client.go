@@ -0,0 +1,50 @@
+package flows
+
+import (
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+	"context"
+)
+
+type aiClientKey struct{}
+
+type ticketSubmitterKey struct{}
+
+// TicketSubmitter 保存工单并返回带真实 ID 的工单
+type TicketSubmitter interface {
+	SubmitTicket(ctx context.Context, ticket model.Ticket) (*model.Ticket, error)
+}
+
+// WithAIClient 将 AI 后端注入 ctx，Flow 处理器从 ctx 中获取（由 service 层调用）
+func WithAIClient(ctx context.Context, client aiclient.Backend) context.Context {
+	return context.WithValue(ctx, aiClientKey{}, client)
+}
+
+// aiClientFrom 取出 ctx 中的 AI 后端，未注入时返回 nil，处理器使用 Mock 数据
+func aiClientFrom(ctx context.Context) aiclient.Backend {
+	client, _ := ctx.Value(aiClientKey{}).(aiclient.Backend)
+	return client
+}
+
+// WithTicketSubmitter 将工单服务注入 ctx（由 service 层调用）
+func WithTicketSubmitter(ctx context.Context, submitter TicketSubmitter) context.Context {
+	return context.WithValue(ctx, ticketSubmitterKey{}, submitter)
+}
+
+// ticketSubmitterFrom 取出 ctx 中的工单服务，未注入时返回 nil
+func ticketSubmitterFrom(ctx context.Context) TicketSubmitter {
+	submitter, _ := ctx.Value(ticketSubmitterKey{}).(TicketSubmitter)
+	return submitter
+}
+
+// callFlowTool 调用工具并检查结果中的 error 字段，工具报告失败时返回 *aiclient.ToolError
+func callFlowTool(aiClient aiclient.Backend, toolName string, args map[string]string) (string, error) {
+	result, err := aiClient.CallFlowTool(toolName, args)
+	if err != nil {
+		return "", err
+	}
+	if err := aiclient.CheckToolResult(toolName, result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -4,7 +4,15 @@ import (
 	"ai-agent/model"
 	"context"
 	"fmt"
-	"github.com/google/uuid"
+	"log"
+)
+
+// 客户服务流程的槽位
+var (
+	csCategorySlot = Slot{Name: "category", Type: SlotChoice, Label: "问题分类",
+		Options: []string{"产品问题", "订单问题", "退款问题", "其他"}}
+	csDescriptionSlot = Slot{Name: "description", Type: SlotText, Label: "问题描述", MinLength: 5, MaxLength: 500}
+	csContactSlot     = Slot{Name: "contact", Type: SlotContact, Label: "联系方式"}
 )
 
 // ==================== 客户服务流程处理器 ====================
@@ -16,36 +24,51 @@ func HandleCSStart(ctx context.Context, session *model.Session, userMessage stri
 
 // 获取问题分类
 func HandleCSAskCategory(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	if session.FlowState == nil {
-		session.FlowState = make(map[string]interface{})
+	if _, reply, done, ok := fillSlot(ctx, session, csCategorySlot, userMessage); !ok {
+		return reply, done, "ask_category", nil
 	}
-	session.FlowState["category"] = userMessage
 
 	return "请详细描述您的问题或需求。", false, "ask_description", nil
 }
 
 // 获取问题描述
 func HandleCSAskDescription(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	if session.FlowState == nil {
-		session.FlowState = make(map[string]interface{})
+	if _, reply, done, ok := fillSlot(ctx, session, csDescriptionSlot, userMessage); !ok {
+		return reply, done, "ask_description", nil
 	}
-	session.FlowState["description"] = userMessage
 
 	return "请留下您的联系方式（电话或邮箱），以便我们及时回复您。", false, "ask_contact", nil
 }
 
 // 获取联系方式并创建工单
 func HandleCSAskContact(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	if session.FlowState == nil {
-		session.FlowState = make(map[string]interface{})
+	contact, reply, done, ok := fillSlot(ctx, session, csContactSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_contact", nil
 	}
-	session.FlowState["contact"] = userMessage
 
 	category, _ := session.FlowState["category"].(string)
 	description, _ := session.FlowState["description"].(string)
 
-	ticketID := uuid.New().String()
+	submitter := ticketSubmitterFrom(ctx)
+	if submitter == nil {
+		return "抱歉，工单服务暂不可用，请稍后重新发送您的联系方式。", false, "ask_contact", nil
+	}
+
+	ticket, err := submitter.SubmitTicket(ctx, model.Ticket{
+		SessionID:   session.ID,
+		UserID:      session.UserID,
+		Intent:      model.IntentFlow,
+		Subject:     fmt.Sprintf("[%s] %s", category, description),
+		Description: description,
+		Category:    category,
+		Contact:     contact,
+	})
+	if err != nil {
+		log.Printf("[Flow customer_service] 创建工单失败: %v", err)
+		return "抱歉，工单提交失败，请稍后重新发送您的联系方式重试。", false, "ask_contact", nil
+	}
 
 	return fmt.Sprintf("感谢您提供的信息！\n\n工单号: %s\n问题分类: %s\n问题描述: %s\n联系方式: %s\n\n我们的客服人员将尽快与您联系。",
-		ticketID, category, description, userMessage), true, "", nil
+		ticket.ID, category, description, contact), true, "", nil
 }
@@ -0,0 +1,143 @@
+package flows
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// ==================== 声明式流程处理器 ====================
+
+// placeholderPattern 匹配提示和工具参数中的 {{key}} 或 {{key.field}} 占位符
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)(?:\.([a-zA-Z0-9_]+))?\s*\}\}`)
+
+// NewDeclarativeStep 将 YAML 中的步骤定义编译为步骤处理器
+func NewDeclarativeStep(flowID, stepID string, def model.FlowStepDefinition) func(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	return func(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+		if session.FlowState == nil {
+			session.FlowState = make(map[string]interface{})
+		}
+
+		if def.Capture != "" && def.Slot != nil {
+			if _, reply, done, ok := fillSlot(ctx, session, slotFromDefinition(def.Capture, *def.Slot), userMessage); !ok {
+				return reply, done, stepID, nil
+			}
+		} else if def.Capture != "" {
+			session.FlowState[def.Capture] = userMessage
+		}
+
+		if def.Confirm == nil {
+			return runFlowAction(ctx, flowID, session, userMessage, def.FlowAction)
+		}
+
+		switch utils.NormalizeConfirm(userMessage) {
+		case "confirm", "yes", "y":
+			return runFlowAction(ctx, flowID, session, userMessage, def.Confirm.OnConfirm)
+		case "modify":
+			return runFlowAction(ctx, flowID, session, userMessage, def.Confirm.OnModify)
+		default:
+			return renderTemplate(def.Confirm.Retry, session, userMessage), false, stepID, nil
+		}
+	}
+}
+
+// slotFromDefinition 将 YAML 中的槽位定义转换为 Slot
+func slotFromDefinition(name string, def model.FlowSlotDefinition) Slot {
+	return Slot{
+		Name:        name,
+		Type:        SlotType(def.Type),
+		Label:       def.Label,
+		Options:     def.Options,
+		MinLength:   def.MinLength,
+		MaxLength:   def.MaxLength,
+		Retry:       def.Retry,
+		MaxAttempts: def.MaxAttempts,
+	}
+}
+
+// runFlowAction 执行步骤动作：清理状态、调用工具、渲染回复
+func runFlowAction(ctx context.Context, flowID string, session *model.Session, userMessage string, action model.FlowAction) (string, bool, string, error) {
+	for _, key := range action.Clear {
+		delete(session.FlowState, key)
+	}
+
+	if action.Tool != nil {
+		if err := runFlowTool(ctx, flowID, session, userMessage, action.Tool); err != nil {
+			log.Printf("[Flow %s] 调用工具失败: %v", flowID, err)
+			reply := action.Tool.OnError
+			if reply == "" {
+				reply = "操作失败，请稍后重试"
+			}
+			return renderTemplate(reply, session, userMessage), true, "", nil
+		}
+	}
+
+	reply := renderTemplate(action.Prompt, session, userMessage)
+	if action.Done || action.Next == "" {
+		return reply, true, "", nil
+	}
+	return reply, false, action.Next, nil
+}
+
+// runFlowTool 调用 Python 工具，并把结果写入 FlowState
+// 工具在结果中报告 error 时返回错误，由调用方走 on_error 分支
+func runFlowTool(ctx context.Context, flowID string, session *model.Session, userMessage string, tool *model.FlowToolDefinition) error {
+	aiClient := aiClientFrom(ctx)
+	if aiClient == nil {
+		return fmt.Errorf("aiClient is nil")
+	}
+
+	args := make(map[string]string, len(tool.Args))
+	for k, v := range tool.Args {
+		args[k] = renderTemplate(v, session, userMessage)
+	}
+
+	log.Printf("[Flow %s] 调用工具 %s, args=%v", flowID, tool.Name, args)
+	result, err := callFlowTool(aiClient, tool.Name, args)
+	if err != nil {
+		return err
+	}
+
+	resultKey := tool.ResultKey
+	if resultKey == "" {
+		resultKey = "tool_result"
+	}
+	session.FlowState[resultKey] = result
+	return nil
+}
+
+// renderTemplate 替换 {{key}} 占位符，{{input}} 表示本轮用户输入，其余从 FlowState 中取值
+// {{key.field}} 将 FlowState[key] 按 JSON 对象解析后取 field 字段，用于读取工具结果
+func renderTemplate(tpl string, session *model.Session, userMessage string) string {
+	return placeholderPattern.ReplaceAllStringFunc(tpl, func(m string) string {
+		sub := placeholderPattern.FindStringSubmatch(m)
+		key, field := sub[1], sub[2]
+		if key == "input" && field == "" {
+			return userMessage
+		}
+		v, ok := session.FlowState[key]
+		if !ok || v == nil {
+			return ""
+		}
+		if field == "" {
+			return fmt.Sprintf("%v", v)
+		}
+		return jsonField(fmt.Sprintf("%v", v), field)
+	})
+}
+
+// jsonField 取 JSON 对象中的字段，解析失败或字段不存在时返回空串
+func jsonField(raw, field string) string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		return ""
+	}
+	if v, ok := obj[field]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	session := &model.Session{FlowState: map[string]interface{}{
+		"order_id": "ORD123",
+		"count":    2,
+		"empty":    nil,
+		"result":   `{"invoice_id": "INV-1", "amount": 99}`,
+		"plain":    "not json",
+	}}
+
+	tests := []struct {
+		name string
+		tpl  string
+		want string
+	}{
+		{"no placeholder", "你好", "你好"},
+		{"flow state", "订单 {{order_id}}", "订单 ORD123"},
+		{"spaces inside braces", "订单 {{ order_id }}", "订单 ORD123"},
+		{"non-string value", "共 {{count}} 件", "共 2 件"},
+		{"user input", "您说：{{input}}", "您说：换个颜色"},
+		{"missing key", "[{{unknown}}]", "[]"},
+		{"nil value", "[{{empty}}]", "[]"},
+		{"repeated", "{{order_id}}/{{order_id}}", "ORD123/ORD123"},
+		{"not a placeholder", "{{order-id}}", "{{order-id}}"},
+		{"json field", "编号 {{tool_result_x.invoice_id}}{{result.invoice_id}}", "编号 INV-1"},
+		{"json number field", "{{result.amount}}", "99"},
+		{"missing json field", "[{{result.status}}]", "[]"},
+		{"field of non-json value", "[{{plain.field}}]", "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTemplate(tt.tpl, session, "换个颜色"); got != tt.want {
+				t.Fatalf("renderTemplate(%q) = %q, want %q", tt.tpl, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("nil flow state", func(t *testing.T) {
+		if got := renderTemplate("[{{order_id}}]", &model.Session{}, ""); got != "[]" {
+			t.Fatalf("got %q", got)
+		}
+	})
+}
+
+func TestDeclarativeStepTool(t *testing.T) {
+	step := NewDeclarativeStep("invoice", "confirm", model.FlowStepDefinition{
+		FlowAction: model.FlowAction{
+			Tool: &model.FlowToolDefinition{
+				Name:    "apply_invoice",
+				Args:    map[string]string{"order_id": "{{order_id}}"},
+				OnError: "开票申请提交失败",
+			},
+			Prompt: "申请编号 {{tool_result.invoice_id}}",
+			Done:   true,
+		},
+	})
+
+	tests := []struct {
+		name      string
+		result    string
+		err       error
+		wantReply string
+	}{
+		{"success", `{"invoice_id": "INV-1", "status": "processing"}`, nil, "申请编号 INV-1"},
+		{"tool reports error", `{"error": "未找到订单 ORD123", "code": "order_not_found"}`, nil, "开票申请提交失败"},
+		{"call fails", "", errors.New("connection refused"), "开票申请提交失败"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.CallFlowToolFunc = func(string, map[string]string) (string, error) { return tt.result, tt.err }
+			ctx := WithAIClient(context.Background(), fake)
+			session := &model.Session{FlowState: map[string]interface{}{"order_id": "ORD123"}}
+
+			reply, done, next, err := step(ctx, session, "")
+			if err != nil {
+				t.Fatalf("step: %v", err)
+			}
+			if reply != tt.wantReply || !done || next != "" {
+				t.Errorf("step = %q, %v, %q, want %q, true, \"\"", reply, done, next, tt.wantReply)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package flows
+
+import (
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ==================== 换货流程处理器 ====================
+
+// 换货流程的槽位
+var (
+	exchangeOrderIDSlot = Slot{Name: "order_id", Type: SlotOrderID, Label: "订单号"}
+	exchangeItemSlot    = Slot{Name: "item", Type: SlotText, Label: "换货商品", MaxLength: 50}
+	exchangeTargetSlot  = Slot{Name: "target", Type: SlotText, Label: "希望换成的尺码或颜色", MaxLength: 50}
+	exchangeReasonSlot  = Slot{Name: "reason", Type: SlotChoice, Label: "换货原因",
+		Options: []string{"尺寸不合适", "颜色不喜欢", "商品质量问题", "其他原因"}}
+)
+
+// exchangeStatusText submit_exchange 返回的状态对应的中文描述
+var exchangeStatusText = map[string]string{
+	"processing": "处理中",
+}
+
+// exchangeResult submit_exchange 的返回结果
+type exchangeResult struct {
+	ExchangeID string `json:"exchange_id"`
+	Status     string `json:"status"`
+}
+
+// 换货流程起始步骤
+func HandleExchangeStart(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	return "欢迎使用换货服务！请提供您要换货的订单号。", false, "ask_order_id", nil
+}
+
+// 获取订单号
+func HandleExchangeAskOrderID(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	orderID, reply, done, ok := fillSlot(ctx, session, exchangeOrderIDSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_order_id", nil
+	}
+
+	return fmt.Sprintf("订单号 %s 已记录。请问您要更换订单中的哪件商品？", orderID), false, "ask_item", nil
+}
+
+// 获取换货商品
+func HandleExchangeAskItem(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	item, reply, done, ok := fillSlot(ctx, session, exchangeItemSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_item", nil
+	}
+
+	return fmt.Sprintf("商品: %s\n请问您希望换成什么尺码或颜色？", item), false, "ask_target", nil
+}
+
+// 获取期望的尺码/颜色
+func HandleExchangeAskTarget(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	if _, reply, done, ok := fillSlot(ctx, session, exchangeTargetSlot, userMessage); !ok {
+		return reply, done, "ask_target", nil
+	}
+
+	return "请问换货原因是什么？\n1. 尺寸不合适\n2. 颜色不喜欢\n3. 商品质量问题\n4. 其他原因", false, "ask_reason", nil
+}
+
+// 获取换货原因
+func HandleExchangeAskReason(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	reason, reply, done, ok := fillSlot(ctx, session, exchangeReasonSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_reason", nil
+	}
+
+	return fmt.Sprintf("请确认以下信息是否正确？\n订单号: %s\n换货商品: %s\n换成: %s\n换货原因: %s\n\n回复【确认】提交换货申请，或回复【修改】重新填写。",
+		session.FlowState["order_id"], session.FlowState["item"], session.FlowState["target"], reason), false, "confirm", nil
+}
+
+// 确认并提交换货申请
+func HandleExchangeConfirm(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	aiClient := aiClientFrom(ctx)
+
+	userMessage = utils.NormalizeConfirm(userMessage)
+
+	if userMessage == "confirm" || userMessage == "yes" || userMessage == "y" {
+		if session.FlowState == nil {
+			return "抱歉，信息不完整，请重新开始换货流程。", true, "", nil
+		}
+
+		orderID, ok := session.FlowState["order_id"].(string)
+		if !ok || orderID == "" {
+			return "抱歉，订单号信息丢失，请重新开始。", true, "", nil
+		}
+
+		item, _ := session.FlowState["item"].(string)
+		target, _ := session.FlowState["target"].(string)
+		reason, _ := session.FlowState["reason"].(string)
+
+		// 没有 AI 后端时无法提交，停留在确认步骤，不能告诉用户已提交
+		if aiClient == nil {
+			log.Printf("[Flow exchange] 未配置 AI 后端，无法提交换货申请, order_id=%s", orderID)
+			return "换货服务暂时不可用，请稍后回复【确认】重试，或回复【修改】重新填写。", false, "confirm", nil
+		}
+
+		// 调用 Python 的 Function Calling 工具提交换货申请
+		log.Printf("[Flow exchange] 调用工具 submit_exchange, order_id=%s", orderID)
+		result, err := callFlowTool(aiClient, "submit_exchange", map[string]string{
+			"order_id": orderID,
+			"item":     item,
+			"target":   target,
+			"reason":   reason,
+		})
+		var toolErr *aiclient.ToolError
+		if errors.As(err, &toolErr) && toolErr.Code == aiclient.ToolErrorOrderNotFound {
+			// 订单号有误，回到填写订单号的步骤，其余信息保留
+			log.Printf("[Flow exchange] 订单不存在, order_id=%s", orderID)
+			session.FlowState["order_id"] = ""
+			return fmt.Sprintf("未找到订单 %s，请重新提供订单号。", orderID), false, "ask_order_id", nil
+		}
+		if err != nil {
+			log.Printf("[Flow exchange] 调用工具失败: %v", err)
+			return "换货申请提交失败，请稍后回复【确认】重试，或回复【修改】重新填写。", false, "confirm", nil
+		}
+		log.Printf("[Flow exchange] 工具返回结果: %s", result)
+
+		var exchange exchangeResult
+		if err := json.Unmarshal([]byte(result), &exchange); err != nil || exchange.ExchangeID == "" {
+			log.Printf("[Flow exchange] 无法解析工具结果: %v", err)
+			return "换货申请提交失败，请稍后回复【确认】重试，或回复【修改】重新填写。", false, "confirm", nil
+		}
+		status := exchangeStatusText[exchange.Status]
+		if status == "" {
+			status = exchange.Status
+		}
+		return fmt.Sprintf("换货申请已提交！\n\n申请编号: %s\n订单号: %s\n换货商品: %s\n换成: %s\n换货原因: %s\n状态: %s\n\n我们的客服人员将在24小时内与您联系。",
+			exchange.ExchangeID, orderID, item, target, reason, status), true, "", nil
+
+	} else if userMessage == "modify" || userMessage == "modify_order_id" {
+		// 重新填写全部信息
+		if session.FlowState == nil {
+			session.FlowState = make(map[string]interface{})
+		}
+		session.FlowState["order_id"] = ""
+		session.FlowState["item"] = ""
+		session.FlowState["target"] = ""
+		session.FlowState["reason"] = ""
+		return "好的，请重新提供订单号。", false, "ask_order_id", nil
+
+	} else {
+		return "请回复【确认】提交换货申请，或回复【修改】重新填写信息。", false, "confirm", nil
+	}
+}
@@ -0,0 +1,74 @@
+package flows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+func TestHandleExchangeConfirm(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    string
+		wantDone  bool
+		wantStep  string
+		wantReply string
+		wantOrder string
+	}{
+		{
+			name:      "submitted",
+			result:    `{"exchange_id": "EXC-ORD123", "order_id": "ORD123", "status": "processing"}`,
+			wantDone:  true,
+			wantReply: "申请编号: EXC-ORD123",
+			wantOrder: "ORD123",
+		},
+		{
+			name:      "unknown order asks again",
+			result:    `{"error": "未找到订单 ORD123", "code": "order_not_found"}`,
+			wantStep:  "ask_order_id",
+			wantReply: "未找到订单 ORD123",
+			wantOrder: "",
+		},
+		{
+			name:      "other tool error stays at confirm",
+			result:    `{"error": "系统繁忙"}`,
+			wantStep:  "confirm",
+			wantReply: "换货申请提交失败",
+			wantOrder: "ORD123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := aiclient.NewFake()
+			fake.CallFlowToolFunc = func(string, map[string]string) (string, error) { return tt.result, nil }
+			ctx := WithAIClient(context.Background(), fake)
+			session := &model.Session{FlowState: map[string]interface{}{
+				"order_id": "ORD123",
+				"item":     "T恤",
+				"target":   "L 码",
+				"reason":   "尺码不合适",
+			}}
+
+			reply, done, step, err := HandleExchangeConfirm(ctx, session, "确认")
+			if err != nil {
+				t.Fatalf("HandleExchangeConfirm: %v", err)
+			}
+			if done != tt.wantDone || step != tt.wantStep {
+				t.Errorf("done/step = %v/%q, want %v/%q", done, step, tt.wantDone, tt.wantStep)
+			}
+			if !strings.Contains(reply, tt.wantReply) {
+				t.Errorf("reply = %q, want containing %q", reply, tt.wantReply)
+			}
+			if strings.Contains(reply, "{") {
+				t.Errorf("reply contains raw tool output: %q", reply)
+			}
+			if got := session.FlowState["order_id"]; got != tt.wantOrder {
+				t.Errorf("order_id = %v, want %q", got, tt.wantOrder)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package flows
 
 import (
-	"ai-agent/internal/aiclient"
 	"ai-agent/model"
 	"context"
 	"fmt"
@@ -10,15 +9,6 @@ import (
 	"strings"
 )
 
-var (
-	aiClient *aiclient.Client
-)
-
-// SetAIClient 设置 AI 客户端（由 service 层调用）
-func SetAIClient(client *aiclient.Client) {
-	aiClient = client
-}
-
 // extractLogisticsOrderID 从用户消息中提取订单号（物流查询用）
 func extractLogisticsOrderID(message string) string {
 	// 先尝试直接匹配 5-20 位数字
@@ -49,8 +39,13 @@ func extractLogisticsOrderID(message string) string {
 
 // ==================== 物流查询流程处理器 ====================
 
+// logisticsOrderIDSlot 物流查询流程的订单号槽位
+var logisticsOrderIDSlot = Slot{Name: "order_id", Type: SlotOrderID, Label: "订单号"}
+
 // HandleLogisticsStart 物流查询起始步骤
 func HandleLogisticsStart(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	aiClient := aiClientFrom(ctx)
+
 	// 尝试从用户消息中提取订单号
 	orderID := extractLogisticsOrderID(userMessage)
 
@@ -91,7 +86,12 @@ func HandleLogisticsStart(ctx context.Context, session *model.Session, userMessa
 
 // HandleLogisticsQuery 查询物流信息
 func HandleLogisticsQuery(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	orderID := userMessage
+	aiClient := aiClientFrom(ctx)
+
+	orderID, reply, done, ok := fillSlot(ctx, session, logisticsOrderIDSlot, userMessage)
+	if !ok {
+		return reply, done, "query", nil
+	}
 
 	log.Printf("[Flow logistics] 收到查询请求, order_id=%s", orderID)
 
@@ -11,6 +11,9 @@ import (
 
 // ==================== 订单查询流程处理器 ====================
 
+// orderQueryOrderIDSlot 订单查询流程的订单号槽位
+var orderQueryOrderIDSlot = Slot{Name: "order_id", Type: SlotOrderID, Label: "订单号"}
+
 // extractOrderID 从用户消息中提取订单号
 func extractOrderID(message string) string {
 	log.Printf("[extractOrderID] input: %s", message)
@@ -47,6 +50,8 @@ func extractOrderID(message string) string {
 
 // 订单查询起始步骤
 func HandleOrderQueryStart(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
+	aiClient := aiClientFrom(ctx)
+
 	// 尝试从用户消息中提取订单号
 	orderID := extractOrderID(userMessage)
 
@@ -87,7 +92,12 @@ func HandleOrderQueryStart(ctx context.Context, session *model.Session, userMess
 
 // 查询订单状态
 func HandleOrderQueryProcessing(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	orderID := userMessage
+	aiClient := aiClientFrom(ctx)
+
+	orderID, reply, done, ok := fillSlot(ctx, session, orderQueryOrderIDSlot, userMessage)
+	if !ok {
+		return reply, done, "processing", nil
+	}
 
 	// 调用 Python 的 Function Calling 工具查询订单信息
 	if aiClient != nil {
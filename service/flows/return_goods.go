@@ -9,6 +9,13 @@ import (
 
 // ==================== 退货流程处理器 ====================
 
+// 退货流程的槽位
+var (
+	returnOrderIDSlot = Slot{Name: "order_id", Type: SlotOrderID, Label: "订单号"}
+	returnReasonSlot  = Slot{Name: "reason", Type: SlotChoice, Label: "退货原因",
+		Options: []string{"商品质量问题", "收到商品与描述不符", "尺寸/颜色不合适", "其他原因"}}
+)
+
 // 退货流程起始步骤
 func HandleReturnGoodsStart(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
 	return "欢迎使用退货服务！请提供您要退货的订单号。", false, "ask_order_id", nil
@@ -16,25 +23,25 @@ func HandleReturnGoodsStart(ctx context.Context, session *model.Session, userMes
 
 // 获取订单号
 func HandleReturnGoodsAskOrderID(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	// 保存订单号到FlowState
-	if session.FlowState == nil {
-		session.FlowState = make(map[string]interface{})
+	// 校验订单号并保存到FlowState
+	orderID, reply, done, ok := fillSlot(ctx, session, returnOrderIDSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_order_id", nil
 	}
-	session.FlowState["order_id"] = userMessage
 
-	return fmt.Sprintf("订单号 %s 已记录。请问退货原因是什么？\n1. 商品质量问题\n2. 收到商品与描述不符\n3. 尺寸/颜色不合适\n4. 其他原因", userMessage), false, "ask_reason", nil
+	return fmt.Sprintf("订单号 %s 已记录。请问退货原因是什么？\n1. 商品质量问题\n2. 收到商品与描述不符\n3. 尺寸/颜色不合适\n4. 其他原因", orderID), false, "ask_reason", nil
 }
 
 // 获取退货原因
 func HandleReturnGoodsAskReason(ctx context.Context, session *model.Session, userMessage string) (string, bool, string, error) {
-	// 保存退货原因
-	if session.FlowState == nil {
-		session.FlowState = make(map[string]interface{})
+	// 校验并保存退货原因
+	reason, reply, done, ok := fillSlot(ctx, session, returnReasonSlot, userMessage)
+	if !ok {
+		return reply, done, "ask_reason", nil
 	}
-	session.FlowState["reason"] = userMessage
 
 	return fmt.Sprintf("退货原因: %s\n\n请确认以下信息是否正确？\n订单号: %s\n退货原因: %s\n\n回复【确认】提交退货申请，或回复【修改】重新填写。",
-		userMessage, session.FlowState["order_id"], userMessage), false, "confirm", nil
+		reason, session.FlowState["order_id"], reason), false, "confirm", nil
 }
 
 // 确认退货信息
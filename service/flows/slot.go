@@ -0,0 +1,236 @@
+package flows
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==================== 槽位校验 ====================
+
+// SlotType 槽位类型，决定如何从用户输入中提取和校验值
+type SlotType string
+
+const (
+	SlotOrderID SlotType = "order_id" // 订单号，基于 extractOrderID
+	SlotPhone   SlotType = "phone"    // 手机号
+	SlotEmail   SlotType = "email"    // 邮箱
+	SlotContact SlotType = "contact"  // 手机号或邮箱
+	SlotChoice  SlotType = "choice"   // 枚举选项，可回复序号或选项内容
+	SlotText    SlotType = "text"     // 自由文本，可限制长度
+)
+
+// defaultSlotMaxAttempts 连续校验失败多少次后转工单
+const defaultSlotMaxAttempts = 3
+
+var (
+	// phonePattern 不含边界，extractPhone 负责排除前后紧邻数字的匹配
+	phonePattern = regexp.MustCompile(`(?:\+?86)?(1[3-9][0-9]{9})`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+// Slot 步骤需要用户填写的一个槽位
+type Slot struct {
+	Name        string   // 保存到 FlowState 的键
+	Type        SlotType // 槽位类型
+	Label       string   // 提示中使用的名称，例如“订单号”
+	Options     []string // SlotChoice 的选项
+	MinLength   int      // SlotText 的最少字数，0 表示不限
+	MaxLength   int      // SlotText 的最多字数，0 表示不限
+	Retry       string   // 校验失败时的提示，为空时按类型生成
+	MaxAttempts int      // 连续失败多少次后转工单，为 0 时使用 defaultSlotMaxAttempts
+}
+
+// ValidSlotType 判断槽位类型是否受支持
+func ValidSlotType(t SlotType) bool {
+	switch t {
+	case SlotOrderID, SlotPhone, SlotEmail, SlotContact, SlotChoice, SlotText:
+		return true
+	}
+	return false
+}
+
+// Extract 从用户输入中提取槽位值，无法识别时返回 false
+func (s Slot) Extract(message string) (string, bool) {
+	message = strings.TrimSpace(message)
+
+	switch s.Type {
+	case SlotOrderID:
+		orderID := extractOrderID(message)
+		return orderID, orderID != ""
+
+	case SlotPhone:
+		return extractPhone(message)
+
+	case SlotEmail:
+		email := emailPattern.FindString(message)
+		return email, email != ""
+
+	case SlotContact:
+		if email := emailPattern.FindString(message); email != "" {
+			return email, true
+		}
+		return extractPhone(message)
+
+	case SlotChoice:
+		return matchChoice(message, s.Options)
+
+	case SlotText:
+		n := utf8.RuneCountInString(message)
+		if n == 0 || (s.MinLength > 0 && n < s.MinLength) || (s.MaxLength > 0 && n > s.MaxLength) {
+			return "", false
+		}
+		return message, true
+	}
+
+	return "", false
+}
+
+// RetryPrompt 校验失败时的提示
+func (s Slot) RetryPrompt() string {
+	if s.Retry != "" {
+		return s.Retry
+	}
+
+	switch s.Type {
+	case SlotOrderID:
+		return "未能识别订单号，请提供 5-20 位数字的订单号。"
+	case SlotPhone:
+		return "未能识别手机号，请提供 11 位手机号码。"
+	case SlotEmail:
+		return "未能识别邮箱地址，请重新输入，例如 name@example.com。"
+	case SlotContact:
+		return "未能识别联系方式，请提供 11 位手机号码或邮箱地址。"
+	case SlotChoice:
+		var b strings.Builder
+		fmt.Fprintf(&b, "请从以下选项中选择%s，回复序号即可：", s.Label)
+		for i, opt := range s.Options {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, opt)
+		}
+		return b.String()
+	case SlotText:
+		switch {
+		case s.MinLength > 0 && s.MaxLength > 0:
+			return fmt.Sprintf("请输入%s（%d-%d 个字）。", s.Label, s.MinLength, s.MaxLength)
+		case s.MinLength > 0:
+			return fmt.Sprintf("请输入%s（至少 %d 个字）。", s.Label, s.MinLength)
+		case s.MaxLength > 0:
+			return fmt.Sprintf("请输入%s（不超过 %d 个字）。", s.Label, s.MaxLength)
+		}
+		return fmt.Sprintf("请输入%s。", s.Label)
+	}
+
+	return fmt.Sprintf("请重新输入%s。", s.Label)
+}
+
+// fillSlot 校验用户输入并写入 FlowState，失败次数记录在 session.SlotRetry
+// ok 为 false 时调用方直接返回 reply 和 done 并停留在当前步骤；
+// 连续失败达到上限时会创建工单，此时 done 为 true
+func fillSlot(ctx context.Context, session *model.Session, slot Slot, userMessage string) (value, reply string, done, ok bool) {
+	if session.FlowState == nil {
+		session.FlowState = make(map[string]interface{})
+	}
+
+	if value, ok := slot.Extract(userMessage); ok {
+		session.FlowState[slot.Name] = value
+		session.SlotRetry = nil
+		return value, "", false, true
+	}
+
+	attempts := 1
+	if r := session.SlotRetry; r != nil && r.FlowID == session.FlowID && r.Step == session.CurrentStep && r.Slot == slot.Name {
+		attempts = r.Attempts + 1
+	}
+	session.SlotRetry = &model.SlotRetry{FlowID: session.FlowID, Step: session.CurrentStep, Slot: slot.Name, Attempts: attempts}
+	log.Printf("[Flow %s] 槽位 %s 校验失败 (%d 次), input=%s", session.FlowID, slot.Name, attempts, userMessage)
+
+	maxAttempts := slot.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultSlotMaxAttempts
+	}
+	if attempts < maxAttempts {
+		return "", slot.RetryPrompt(), false, false
+	}
+
+	return "", escalateSlot(ctx, session, slot, userMessage, attempts), true, false
+}
+
+// escalateSlot 多次校验失败后创建工单交给人工处理
+func escalateSlot(ctx context.Context, session *model.Session, slot Slot, userMessage string, attempts int) string {
+	submitter := ticketSubmitterFrom(ctx)
+	if submitter == nil {
+		return fmt.Sprintf("抱歉，多次未能识别您的%s，请稍后重试或联系人工客服。", slot.Label)
+	}
+
+	ticket, err := submitter.SubmitTicket(ctx, model.Ticket{
+		SessionID:   session.ID,
+		UserID:      session.UserID,
+		Intent:      model.IntentFlow,
+		Subject:     fmt.Sprintf("[%s] 多次未能识别%s", session.FlowID, slot.Label),
+		Description: fmt.Sprintf("Flow %s 连续 %d 次未能识别%s，最后一次输入: %s", session.FlowID, attempts, slot.Label, userMessage),
+	})
+	if err != nil {
+		log.Printf("[Flow %s] 槽位 %s 转工单失败: %v", session.FlowID, slot.Name, err)
+		return fmt.Sprintf("抱歉，多次未能识别您的%s，请稍后重试或联系人工客服。", slot.Label)
+	}
+
+	return fmt.Sprintf("抱歉，多次未能识别您的%s，已为您创建工单（工单号: %s），客服人员将尽快与您联系。", slot.Label, ticket.ID)
+}
+
+// extractPhone 提取手机号，允许空格、短横线分隔和 +86 前缀
+// 前后紧邻数字的匹配是更长数字串的一部分（例如订单号），不视为手机号
+func extractPhone(message string) (string, bool) {
+	compact := strings.NewReplacer(" ", "", "-", "", "　", "").Replace(message)
+	for _, loc := range phonePattern.FindAllStringSubmatchIndex(compact, -1) {
+		if isDigitAt(compact, loc[0]-1) || isDigitAt(compact, loc[1]) {
+			continue
+		}
+		return compact[loc[2]:loc[3]], true
+	}
+	return "", false
+}
+
+// isDigitAt 判断 s[i] 是否为 ASCII 数字，越界时返回 false
+func isDigitAt(s string, i int) bool {
+	return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+// matchChoice 按序号或选项内容匹配，返回选项原文
+func matchChoice(message string, options []string) (string, bool) {
+	msg := strings.TrimRight(utils.NormalizeString(message), ".．、。")
+	if msg == "" {
+		return "", false
+	}
+
+	if n, err := strconv.Atoi(msg); err == nil {
+		if n >= 1 && n <= len(options) {
+			return options[n-1], true
+		}
+		return "", false
+	}
+
+	// 回复中包含完整选项，例如“因为质量问题”
+	for _, opt := range options {
+		if strings.Contains(msg, utils.NormalizeString(opt)) {
+			return opt, true
+		}
+	}
+
+	// 回复是某个选项的一部分，例如“质量”，只有唯一匹配时才采纳
+	match := ""
+	for _, opt := range options {
+		if utf8.RuneCountInString(msg) >= 2 && strings.Contains(utils.NormalizeString(opt), msg) {
+			if match != "" {
+				return "", false
+			}
+			match = opt
+		}
+	}
+	return match, match != ""
+}
@@ -0,0 +1,30 @@
+package flows
+
+import "testing"
+
+func TestExtractPhone(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+		wantOK  bool
+	}{
+		{"plain", "13812345678", "13812345678", true},
+		{"in a sentence", "我的手机是13812345678，谢谢", "13812345678", true},
+		{"separated", "138 1234-5678", "13812345678", true},
+		{"country code", "+86 138 1234 5678", "13812345678", true},
+		{"too long", "138123456789", "", false},
+		{"inside a longer number", "订单号2138123456780", "", false},
+		{"after an order id", "订单号20240101，手机13812345678", "13812345678", true},
+		{"too short", "1381234567", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractPhone(tt.message)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("extractPhone(%q) = %q, %v, want %q, %v", tt.message, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"ai-agent/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrNotHandedOff      = errors.New("session is not handed off to an agent")
+	ErrHandoffClaimed    = errors.New("session is already claimed by another agent")
+	ErrHandoffNotClaimed = errors.New("session is not claimed by this agent")
+)
+
+// RequestHandoff 将会话转给人工客服，已转人工的会话直接返回当前状态
+func (s *ChatService) RequestHandoff(ctx context.Context, sessionID, userID, reason string) (*model.Session, error) {
+	session, err := s.getOrCreateSession(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.State == model.SessionHandoff {
+		return session, nil
+	}
+
+	if err := s.startHandoff(ctx, session, reason); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// startHandoff 中止进行中的 Flow，保存会话并放入人工等待队列
+func (s *ChatService) startHandoff(ctx context.Context, session *model.Session, reason string) error {
+	now := time.Now()
+	session.State = model.SessionHandoff
+	session.FlowID = ""
+	session.CurrentStep = ""
+	session.FlowState = nil
+	session.Clarification = nil
+	session.StepHistory = nil
+	session.Handoff = &model.Handoff{
+		Reason:      reason,
+		RequestedAt: now.Format(time.RFC3339Nano),
+	}
+	session.UpdatedAt = now.Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		return err
+	}
+	if err := s.handoffQueue.Enqueue(ctx, session.ID, now); err != nil {
+		return fmt.Errorf("加入人工等待队列失败: %w", err)
+	}
+
+	log.Printf("[Session %s] 转人工, reason=%s", session.ID, reason)
+	return nil
+}
+
+// ListWaitingHandoffs 列出等待人工接入的会话，最早转人工的在前
+func (s *ChatService) ListWaitingHandoffs(ctx context.Context) ([]model.HandoffSession, error) {
+	ids, err := s.handoffQueue.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.HandoffSession, 0, len(ids))
+	for _, id := range ids {
+		session, err := s.store.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// 会话已过期或已交还机器人，顺手清理队列
+		if session == nil || session.State != model.SessionHandoff || session.Handoff == nil {
+			if err := s.handoffQueue.Remove(ctx, id); err != nil {
+				log.Printf("[Session %s] 清理人工等待队列失败: %v", id, err)
+			}
+			continue
+		}
+
+		item := model.HandoffSession{
+			SessionID:   session.ID,
+			UserID:      session.UserID,
+			Reason:      session.Handoff.Reason,
+			RequestedAt: session.Handoff.RequestedAt,
+			Pending:     session.Handoff.Pending,
+		}
+		for i := len(session.Messages) - 1; i >= 0; i-- {
+			if session.Messages[i].Role == model.RoleUser {
+				item.LastMessage = session.Messages[i].Content
+				break
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// ClaimHandoff 坐席接入等待中的会话，同一会话只能被一个坐席接入
+func (s *ChatService) ClaimHandoff(ctx context.Context, sessionID, agentID string) (*model.Session, error) {
+	session, err := s.handoffSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Handoff.AgentID == agentID {
+		return session, nil
+	}
+
+	claimed, err := s.handoffQueue.Claim(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrHandoffClaimed
+	}
+
+	session.Handoff.AgentID = agentID
+	session.Handoff.ClaimedAt = time.Now().Format(time.RFC3339Nano)
+	session.UpdatedAt = session.Handoff.ClaimedAt
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		// 保存失败时放回队列，让其他坐席可以接入
+		if qerr := s.handoffQueue.Enqueue(ctx, sessionID, time.Now()); qerr != nil {
+			log.Printf("[Session %s] 放回人工等待队列失败: %v", sessionID, qerr)
+		}
+		return nil, err
+	}
+
+	log.Printf("[Session %s] 坐席 %s 已接入", sessionID, agentID)
+	s.pushHandoffNotice(ctx, sessionID, "人工客服已接入，请问有什么可以帮您？")
+	return session, nil
+}
+
+// ReplyHandoff 坐席回复用户，消息以 assistant 角色记录并推送到会话
+func (s *ChatService) ReplyHandoff(ctx context.Context, sessionID, agentID, content string) (*model.Session, error) {
+	session, err := s.handoffSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Handoff.AgentID != agentID {
+		return nil, ErrHandoffNotClaimed
+	}
+
+	now := time.Now().Format(time.RFC3339Nano)
+	s.addMessage(session, model.RoleAssistant, content)
+	session.Messages[len(session.Messages)-1].AgentID = agentID
+	session.Handoff.Pending = 0
+	session.UpdatedAt = now
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		return nil, err
+	}
+
+	if err := s.PushToSession(ctx, sessionID, PushSourceAgent, content); err != nil {
+		log.Printf("[Session %s] 推送坐席回复失败: %v", sessionID, err)
+	}
+	return session, nil
+}
+
+// ReleaseHandoff 坐席结束服务，会话交还机器人
+func (s *ChatService) ReleaseHandoff(ctx context.Context, sessionID, agentID string) (*model.Session, error) {
+	session, err := s.handoffSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Handoff.AgentID != "" && session.Handoff.AgentID != agentID {
+		return nil, ErrHandoffNotClaimed
+	}
+
+	session.State = model.SessionNew
+	session.Handoff = nil
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		return nil, err
+	}
+	if err := s.handoffQueue.Remove(ctx, sessionID); err != nil {
+		log.Printf("[Session %s] 清理人工等待队列失败: %v", sessionID, err)
+	}
+
+	log.Printf("[Session %s] 坐席 %s 已交还机器人", sessionID, agentID)
+	s.pushHandoffNotice(ctx, sessionID, "人工服务已结束，智能助手将继续为您服务。")
+	return session, nil
+}
+
+// handleHandoff 已转人工的会话：记录用户消息等待坐席回复，不经过 DecisionLayer
+func (s *ChatService) handleHandoff(ctx context.Context, req model.ChatRequest, session *model.Session) (*model.ChatResponse, error) {
+	s.addMessage(session, model.RoleUser, req.Message)
+	if session.Handoff == nil {
+		session.Handoff = &model.Handoff{RequestedAt: time.Now().Format(time.RFC3339Nano)}
+	}
+	session.Handoff.Pending++
+	session.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存失败: %v", session.ID, err)
+		return nil, err
+	}
+
+	// 坐席接入后回复通过推送送达，这里不再生成机器人回复
+	reply := ""
+	if session.Handoff.AgentID == "" {
+		reply = "正在为您转接人工客服，请稍候，您的消息已转达。"
+	}
+
+	return &model.ChatResponse{
+		Reply:     reply,
+		Type:      model.IntentHandoff,
+		Session:   session.State,
+		SessionID: session.ID,
+	}, nil
+}
+
+// handoffSession 获取处于人工接管中的会话
+func (s *ChatService) handoffSession(ctx context.Context, sessionID string) (*model.Session, error) {
+	session, err := s.store.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.State != model.SessionHandoff || session.Handoff == nil {
+		return nil, ErrNotHandedOff
+	}
+	return session, nil
+}
+
+// pushHandoffNotice 推送人工服务状态提示，失败只记录日志
+func (s *ChatService) pushHandoffNotice(ctx context.Context, sessionID, content string) {
+	if err := s.PushToSession(ctx, sessionID, PushSourceSystem, content); err != nil {
+		log.Printf("[Session %s] 推送人工服务提示失败: %v", sessionID, err)
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"ai-agent/model"
+	"ai-agent/utils"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// exampleWeight 示例句相似度的权重，相当于命中一个两字关键词
+const exampleWeight = 2.0
+
+// exampleMinSimilarity 示例句相似度低于该值时不计分
+const exampleMinSimilarity = 0.5
+
+// IntentMatcher 基于关键词和示例句的本地意图匹配器
+// 在 Python 意图识别不可用或置信度过低时兜底
+type IntentMatcher struct {
+	intents []matcherIntent
+}
+
+type matcherIntent struct {
+	def      model.IntentDefinition
+	order    int
+	keywords []string
+	examples [][]string // 每个示例句的二元字组
+}
+
+// IntentMatch 一次本地匹配的结果
+type IntentMatch struct {
+	Intent     model.IntentDefinition
+	Score      float64
+	Confidence float64
+}
+
+// NewIntentMatcher 创建本地意图匹配器，只使用已启用的意图
+func NewIntentMatcher(defs []model.IntentDefinition) *IntentMatcher {
+	m := &IntentMatcher{}
+	for i, d := range defs {
+		if !d.Enabled {
+			continue
+		}
+
+		mi := matcherIntent{def: d, order: i}
+		for _, k := range d.Keywords {
+			if k = utils.NormalizeString(k); k != "" {
+				mi.keywords = append(mi.keywords, k)
+			}
+		}
+		for _, e := range d.Examples {
+			if grams := bigrams(utils.NormalizeString(e)); len(grams) > 0 {
+				mi.examples = append(mi.examples, grams)
+			}
+		}
+		m.intents = append(m.intents, mi)
+	}
+	return m
+}
+
+// Match 返回所有命中的意图，按得分、优先级、配置顺序排序
+func (m *IntentMatcher) Match(message string) []IntentMatch {
+	msg := utils.NormalizeString(message)
+	if msg == "" {
+		return nil
+	}
+	msgGrams := bigrams(msg)
+
+	type scored struct {
+		IntentMatch
+		order int
+	}
+	var results []scored
+
+	for _, mi := range m.intents {
+		score := 0.0
+
+		// 关键词：中文没有分词，直接按子串匹配，命中的关键词越长越具体
+		for _, k := range mi.keywords {
+			if strings.Contains(msg, k) {
+				score += float64(utf8.RuneCountInString(k))
+			}
+		}
+
+		// 示例句：按二元字组的 Dice 系数计算相似度
+		best := 0.0
+		for _, grams := range mi.examples {
+			if sim := diceSimilarity(msgGrams, grams); sim > best {
+				best = sim
+			}
+		}
+		if best >= exampleMinSimilarity {
+			score += best * exampleWeight
+		}
+
+		if score > 0 {
+			results = append(results, scored{IntentMatch{Intent: mi.def, Score: score}, mi.order})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Intent.Priority != results[j].Intent.Priority {
+			return results[i].Intent.Priority > results[j].Intent.Priority
+		}
+		return results[i].order < results[j].order
+	})
+
+	// 置信度同时考虑绝对得分和与第二名的差距，得分相同的意图会拉低置信度
+	// 得分相同但优先级更低的意图已由优先级决出胜负，不计入第一名的竞争者
+	matches := make([]IntentMatch, len(results))
+	for i, r := range results {
+		runnerUp := 0.0
+		if i == 0 {
+			for _, other := range results[1:] {
+				if other.Score == r.Score && other.Intent.Priority < r.Intent.Priority {
+					continue
+				}
+				runnerUp = other.Score
+				break
+			}
+		} else {
+			runnerUp = results[0].Score
+		}
+		r.Confidence = r.Score / (r.Score + runnerUp + 1)
+		matches[i] = r.IntentMatch
+	}
+	return matches
+}
+
+// Best 返回得分最高的意图，没有命中时返回 nil
+func (m *IntentMatcher) Best(message string) *IntentMatch {
+	matches := m.Match(message)
+	if len(matches) == 0 {
+		return nil
+	}
+	return &matches[0]
+}
+
+// bigrams 将字符串切分为二元字组，单字字符串返回自身
+func bigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		if len(runes) == 1 {
+			return []string{s}
+		}
+		return nil
+	}
+
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+// diceSimilarity 计算两个二元字组集合的 Dice 系数
+func diceSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int, len(a))
+	for _, g := range a {
+		counts[g]++
+	}
+	common := 0
+	for _, g := range b {
+		if counts[g] > 0 {
+			counts[g]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
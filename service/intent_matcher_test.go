@@ -0,0 +1,61 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"ai-agent/model"
+)
+
+func TestIntentMatcherPriorityTie(t *testing.T) {
+	shipping := model.IntentDefinition{ID: "faq_shipping", Enabled: true, Priority: 6, Keywords: []string{"发货", "几天到"}}
+	refund := model.IntentDefinition{ID: "faq_refund_time", Enabled: true, Priority: 5, Keywords: []string{"退款到账", "几天到"}}
+	samePriority := refund
+	samePriority.Priority = 6
+
+	tests := []struct {
+		name      string
+		defs      []model.IntentDefinition
+		message   string
+		wantID    string
+		wantAbove float64 // 第一名置信度应不低于该值
+		wantBelow float64 // 第一名置信度应低于该值，0 表示不检查
+	}{
+		{name: "priority breaks the tie", defs: []model.IntentDefinition{refund, shipping}, message: "快递几天到", wantID: "faq_shipping", wantAbove: 0.6},
+		{name: "equal priority stays ambiguous", defs: []model.IntentDefinition{shipping, samePriority}, message: "几天到", wantID: "faq_shipping", wantBelow: 0.6},
+		{name: "higher score wins over priority", defs: []model.IntentDefinition{shipping, refund}, message: "退款到账几天到", wantID: "faq_refund_time", wantAbove: 0.6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best := NewIntentMatcher(tt.defs).Best(tt.message)
+			if best == nil {
+				t.Fatalf("Best(%q) = nil", tt.message)
+			}
+			if best.Intent.ID != tt.wantID {
+				t.Errorf("Best(%q) = %s, want %s", tt.message, best.Intent.ID, tt.wantID)
+			}
+			if best.Confidence < tt.wantAbove {
+				t.Errorf("confidence = %.2f, want >= %.2f", best.Confidence, tt.wantAbove)
+			}
+			if tt.wantBelow > 0 && best.Confidence >= tt.wantBelow {
+				t.Errorf("confidence = %.2f, want < %.2f", best.Confidence, tt.wantBelow)
+			}
+		})
+	}
+}
+
+func TestIntentMatcherShippedConfig(t *testing.T) {
+	intentConfig, err := LoadIntentConfig(filepath.Join("..", "config", "intents.yaml"))
+	if err != nil {
+		t.Fatalf("LoadIntentConfig: %v", err)
+	}
+
+	best := NewIntentMatcher(intentConfig.Intents).Best("几天到")
+	if best == nil || best.Intent.ID != "faq_shipping" {
+		t.Fatalf("Best(几天到) = %+v, want faq_shipping", best)
+	}
+	if best.Confidence < intentConfig.ConfidenceThreshold {
+		t.Errorf("confidence = %.2f, below threshold %.2f", best.Confidence, intentConfig.ConfidenceThreshold)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"ai-agent/model"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// WatchIntentConfig 记录意图配置路径，并按 interval 轮询文件变化自动热加载
+// interval <= 0 时不轮询，只能通过 ReloadIntentConfig（管理接口）触发
+func (s *ChatService) WatchIntentConfig(ctx context.Context, path string, interval time.Duration) {
+	s.intentMu.Lock()
+	s.intentPath = path
+	if info, err := os.Stat(path); err == nil {
+		s.intentModTime = info.ModTime()
+	}
+	s.intentMu.Unlock()
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					log.Printf("[IntentReload] 读取文件信息失败: %v", err)
+					continue
+				}
+
+				s.intentMu.Lock()
+				changed := !info.ModTime().Equal(s.intentModTime)
+				s.intentMu.Unlock()
+				if !changed {
+					continue
+				}
+
+				log.Printf("[IntentReload] 检测到 %s 变更，重新加载", path)
+				if _, err := s.ReloadIntentConfig(); err != nil {
+					log.Printf("[IntentReload] 热加载失败，继续使用旧配置: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ReloadIntentConfig 重新读取并校验意图配置，校验通过后原子替换到 DecisionLayer
+func (s *ChatService) ReloadIntentConfig() (*model.IntentVersion, error) {
+	s.intentMu.Lock()
+	defer s.intentMu.Unlock()
+
+	if s.intentPath == "" {
+		return nil, errors.New("intent config path not set")
+	}
+
+	info, err := os.Stat(s.intentPath)
+	if err != nil {
+		return nil, err
+	}
+	// 无论校验是否通过都记录修改时间，避免对同一份错误配置反复报错
+	s.intentModTime = info.ModTime()
+
+	intentConfig, err := LoadIntentConfig(s.intentPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(intentConfig, Flows); err != nil {
+		return nil, err
+	}
+
+	version := s.decisionLayer.UpdateIntents(intentConfig)
+	return &version, nil
+}
+
+// IntentVersion 返回当前生效的意图配置版本
+func (s *ChatService) IntentVersion() model.IntentVersion {
+	return s.decisionLayer.IntentVersion()
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"ai-agent/model"
+	"context"
+	"log"
+	"time"
+)
+
+// 推送消息来源
+const (
+	PushSourceTicket = "ticket"
+	PushSourceAgent  = "agent"
+	PushSourceSystem = "system"
+)
+
+// PushToSession 向会话推送一条服务端消息，连接在任意实例上的 WebSocket 客户端都会收到
+func (s *ChatService) PushToSession(ctx context.Context, sessionID, source, content string) error {
+	msg := model.PushMessage{
+		SessionID: sessionID,
+		Source:    source,
+		Content:   content,
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+	}
+
+	log.Printf("[Session %s] 推送消息, source=%s", sessionID, source)
+	return s.pushBus.Publish(ctx, msg)
+}
+
+// SubscribeSession 订阅会话的推送消息
+func (s *ChatService) SubscribeSession(ctx context.Context, sessionID string) (<-chan model.PushMessage, func(), error) {
+	return s.pushBus.Subscribe(ctx, sessionID)
+}
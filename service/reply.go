@@ -0,0 +1,53 @@
+package service
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrMessageInProgress 同一消息 ID 的上一次请求仍在处理，等待超时
+var ErrMessageInProgress = errors.New("message is still being processed")
+
+// claimMessage 在执行本轮对话前占用消息 ID，已处理过的消息返回原回复
+// 同一消息正在处理时最多等待 lock.Wait，期间处理完成则返回其回复
+func (s *ChatService) claimMessage(ctx context.Context, req model.ChatRequest) (*model.ChatResponse, error) {
+	deadline := time.Now().Add(s.lock.Wait)
+	for {
+		resp, err := s.replies.Claim(ctx, req.SessionID, req.MessageID)
+		if resp != nil {
+			log.Printf("[Session %s] 消息 %s 已处理，返回原回复", req.SessionID, req.MessageID)
+		}
+		if !errors.Is(err, dao.ErrReplyPending) {
+			return resp, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrMessageInProgress, req.MessageID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// finishMessage 本轮成功时用回复替换处理中标记，失败时删除标记，允许客户端重试
+// 本轮的 ctx 可能已超时，使用不会取消的 ctx 写入
+func (s *ChatService) finishMessage(ctx context.Context, req model.ChatRequest, resp *model.ChatResponse) {
+	ctx = context.WithoutCancel(ctx)
+	if resp == nil {
+		if err := s.replies.Release(ctx, req.SessionID, req.MessageID); err != nil {
+			log.Printf("[Session %s] 释放消息 %s 失败: %v", req.SessionID, req.MessageID, err)
+		}
+		return
+	}
+	if err := s.replies.Put(ctx, req.SessionID, req.MessageID, resp); err != nil {
+		log.Printf("[Session %s] 记录消息 %s 的回复失败: %v", req.SessionID, req.MessageID, err)
+	}
+}
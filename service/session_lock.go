@@ -0,0 +1,61 @@
+package service
+
+import (
+	"ai-agent/dao"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrSessionBusy 会话正在处理其他消息，排队等待超时
+var ErrSessionBusy = errors.New("session is busy with another message")
+
+// lockPollInterval 排队时重试获取会话锁的间隔
+const lockPollInterval = 50 * time.Millisecond
+
+// SessionLockPolicy 会话锁策略，同一会话的消息逐条处理
+type SessionLockPolicy struct {
+	// TTL 租约时长，也是一轮对话的最长处理时间，0 表示不加锁
+	TTL time.Duration
+	// Wait 会话被占用、或同一消息 ID 正在处理时排队等待的最长时间
+	// 0 表示直接返回 ErrSessionBusy / ErrMessageInProgress；消息 ID 的等待不依赖 TTL 是否开启
+	Wait time.Duration
+}
+
+// lockSession 获取会话租约，返回带租约令牌和 TTL 超时的 ctx 以及释放函数
+// 租约过期后本轮的保存会因为令牌过小被存储拒绝，不会覆盖后来者的状态
+func (s *ChatService) lockSession(ctx context.Context, sessionID string) (context.Context, func(), error) {
+	if s.locker == nil || s.lock.TTL <= 0 {
+		return ctx, func() {}, nil
+	}
+
+	deadline := time.Now().Add(s.lock.Wait)
+	for {
+		lease, err := s.locker.Acquire(ctx, sessionID, s.lock.TTL)
+		if err == nil {
+			turnCtx, cancel := context.WithTimeout(dao.WithFence(ctx, lease.Token), s.lock.TTL)
+			release := func() {
+				cancel()
+				// turnCtx 可能已超时，释放锁使用不会超时的 ctx
+				if err := s.locker.Release(context.WithoutCancel(ctx), lease); err != nil {
+					log.Printf("[Session %s] 释放会话锁失败: %v", sessionID, err)
+				}
+			}
+			return turnCtx, release, nil
+		}
+		if !errors.Is(err, dao.ErrSessionLocked) {
+			return nil, nil, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrSessionBusy, sessionID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-agent/dao"
+	"ai-agent/internal/aiclient"
+	"ai-agent/model"
+)
+
+// TestSessionLockFence 租约过期后，原持有者的保存因令牌过小被拒绝，不覆盖后来者写入的状态
+func TestSessionLockFence(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	fake := aiclient.NewFake()
+	fake.RecognizeIntentFunc = func(req model.IntentRecognitionRequest) (*model.IntentRecognitionResponse, error) {
+		return &model.IntentRecognitionResponse{Intent: "faq", Confidence: 0.95}, nil
+	}
+	fake.ChatFunc = func(req model.ChatRequest) (*model.ChatResponse, error) {
+		if req.Message == "slow" {
+			close(entered)
+			<-release
+		}
+		return &model.ChatResponse{Reply: "re: " + req.Message}, nil
+	}
+	svc, store := newTestService(t, fake, ChatOptions{
+		Locker: dao.NewMemorySessionLocker(),
+		Lock:   SessionLockPolicy{TTL: ttl, Wait: time.Second},
+	})
+	ctx := context.Background()
+
+	send(t, svc, "s1", "hello")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// 保存失败只记录日志，这里只关心保存的结果
+		svc.HandleMessage(ctx, model.ChatRequest{SessionID: "s1", UserID: "u1", Message: "slow"})
+	}()
+	<-entered
+	time.Sleep(ttl + 50*time.Millisecond)
+
+	send(t, svc, "s1", "fast")
+	fenced := loadSession(t, store, "s1")
+	close(release)
+	wg.Wait()
+
+	session := loadSession(t, store, "s1")
+	if session.Version != fenced.Version || session.Fence != fenced.Fence {
+		t.Fatalf("expired lease overwrote the session: version %d -> %d, fence %d -> %d",
+			fenced.Version, session.Version, fenced.Fence, session.Fence)
+	}
+	for _, m := range session.Messages {
+		if m.Content == "slow" {
+			t.Fatalf("messages from the expired lease were saved: %+v", session.Messages)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   int64
+		wantErr error
+	}{
+		{"smaller token", session.Fence - 1, dao.ErrStaleFence},
+		{"same token", session.Fence, nil},
+		{"larger token", session.Fence + 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := loadSession(t, store, "s1")
+			current.UpdatedAt = time.Now().Format(time.RFC3339Nano)
+			err := store.SaveWithOptimisticLock(dao.WithFence(ctx, tt.token), current, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("save with token %d: err = %v, want %v", tt.token, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"context"
+	"log"
+	"slices"
+	"time"
+)
+
+// recentHistoryCount FAQ 和 Flow 打断判断携带的最近消息数
+const recentHistoryCount = 10
+
+// SummaryPolicy 会话摘要策略
+type SummaryPolicy struct {
+	// Threshold 未摘要的消息超过该数量时刷新摘要，0 表示不做摘要
+	Threshold int
+	// Keep 刷新时保留原文的最近消息数，其余消息压缩进摘要
+	Keep int
+}
+
+// unsummarizedMessages 返回还没有压缩进摘要的消息
+func unsummarizedMessages(session *model.Session) []model.Message {
+	if session.Summary == nil {
+		return session.Messages
+	}
+	until, err := time.Parse(time.RFC3339Nano, session.Summary.Until)
+	if err != nil {
+		return session.Messages
+	}
+
+	// 消息按时间排序，从后往前找到第一条已摘要的消息
+	i := len(session.Messages)
+	for i > 0 {
+		ts, err := time.Parse(time.RFC3339Nano, session.Messages[i-1].Timestamp)
+		if err == nil && !ts.After(until) {
+			break
+		}
+		i--
+	}
+	return session.Messages[i:]
+}
+
+// historyWithSummary 返回调用 AI 时携带的历史：摘要 + 最近 count 条未摘要的消息
+// count 为 0 时携带全部未摘要的消息
+func historyWithSummary(session *model.Session, count int) []model.Message {
+	messages := unsummarizedMessages(session)
+	if count > 0 && len(messages) > count {
+		messages = messages[len(messages)-count:]
+	}
+	if session.Summary == nil || session.Summary.Content == "" {
+		return messages
+	}
+
+	history := make([]model.Message, 0, len(messages)+1)
+	history = append(history, model.Message{
+		Role:      model.RoleSystem,
+		Content:   "之前的对话摘要：" + session.Summary.Content,
+		Timestamp: session.Summary.Until,
+	})
+	return append(history, messages...)
+}
+
+// refreshSummary 未摘要的消息超过阈值时，在后台把较早的消息交给 AI 压缩进摘要，不阻塞本轮回复，也不占用会话锁
+// 同一会话同时只有一个摘要任务；摘要失败只记录日志，下一轮对话会再次尝试
+func (s *ChatService) refreshSummary(ctx context.Context, session *model.Session) {
+	if s.summary.Threshold <= 0 {
+		return
+	}
+	pending := unsummarizedMessages(session)
+	if len(pending) <= s.summary.Threshold {
+		return
+	}
+	if _, running := s.summarizing.LoadOrStore(session.ID, struct{}{}); running {
+		return
+	}
+	fold := slices.Clone(pending[:len(pending)-s.summary.Keep])
+
+	req := model.SummarizeRequest{SessionID: session.ID, Messages: fold}
+	if session.Summary != nil {
+		req.Summary = session.Summary.Content
+	}
+
+	// 写回时会话锁已释放：不带本轮的租约令牌，也不算一次用户活动
+	bgCtx := dao.WithoutFence(dao.WithKeepTTL(context.WithoutCancel(ctx)))
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer s.summarizing.Delete(req.SessionID)
+		s.saveSummary(bgCtx, req)
+	}()
+}
+
+// saveSummary 调用 AI 生成摘要，写回最新的会话
+// 乐观锁只合并摘要字段，不会覆盖摘要期间新的对话
+func (s *ChatService) saveSummary(ctx context.Context, req model.SummarizeRequest) {
+	resp, err := s.ai.Summarize(req)
+	if err != nil {
+		log.Printf("[Session %s] 刷新摘要失败: %v", req.SessionID, err)
+		return
+	}
+
+	session, err := s.store.Get(ctx, req.SessionID)
+	if err != nil {
+		log.Printf("[Session %s] 获取会话失败: %v", req.SessionID, err)
+		return
+	}
+	if session == nil {
+		return
+	}
+
+	until := req.Messages[len(req.Messages)-1].Timestamp
+	if session.Summary != nil && !summaryBefore(session.Summary.Until, until) {
+		// 其他副本已经写入了覆盖范围不小于本次的摘要
+		return
+	}
+	session.Summary = &model.SessionSummary{
+		Content:   resp.Summary,
+		Until:     until,
+		UpdatedAt: time.Now().Format(time.RFC3339Nano),
+	}
+	if err := s.store.SaveWithOptimisticLock(ctx, session, s.saveRetries); err != nil {
+		log.Printf("[Session %s] 保存摘要失败: %v", req.SessionID, err)
+		return
+	}
+	log.Printf("[Session %s] 刷新摘要，压缩 %d 条消息", req.SessionID, len(req.Messages))
+}
+
+// summaryBefore 已有摘要的截止时间是否早于 until，无法解析时按更早处理
+func summaryBefore(current, until string) bool {
+	a, err1 := time.Parse(time.RFC3339Nano, current)
+	b, err2 := time.Parse(time.RFC3339Nano, until)
+	return err1 != nil || err2 != nil || a.Before(b)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"ai-agent/dao"
+	"ai-agent/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidTicketStatus     = errors.New("invalid ticket status")
+	ErrInvalidTicketTransition = errors.New("invalid ticket status transition")
+)
+
+// ticketSubjectMaxRunes 自动生成的工单标题最大长度
+const ticketSubjectMaxRunes = 30
+
+// ticketTransitions 工单允许的状态流转，closed 为终态
+var ticketTransitions = map[model.TicketStatus][]model.TicketStatus{
+	model.TicketOpen:     {model.TicketPending, model.TicketResolved, model.TicketClosed},
+	model.TicketPending:  {model.TicketOpen, model.TicketResolved, model.TicketClosed},
+	model.TicketResolved: {model.TicketOpen, model.TicketClosed},
+	model.TicketClosed:   {},
+}
+
+// canTransition 判断工单能否从 from 流转到 to
+func canTransition(from, to model.TicketStatus) bool {
+	for _, next := range ticketTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateTicket 根据用户描述创建工单
+func (s *ChatService) CreateTicket(ctx context.Context, userID, sessionID, description string) (*model.Ticket, error) {
+	return s.SubmitTicket(ctx, model.Ticket{
+		SessionID:   sessionID,
+		UserID:      userID,
+		Intent:      model.IntentUnknown,
+		Description: description,
+	})
+}
+
+// SubmitTicket 补全 ID、状态、标题和时间后保存工单
+func (s *ChatService) SubmitTicket(ctx context.Context, ticket model.Ticket) (*model.Ticket, error) {
+	now := time.Now().Format(time.RFC3339Nano)
+	if ticket.ID == "" {
+		ticket.ID = uuid.New().String()
+	}
+	if ticket.Status == "" {
+		ticket.Status = model.TicketOpen
+	}
+	if ticket.Subject == "" {
+		ticket.Subject = ticket.Description
+	}
+	ticket.Subject = ticketSubject(ticket.Subject)
+	ticket.CreatedAt = now
+	ticket.UpdatedAt = now
+
+	if err := s.tickets.Create(ctx, &ticket); err != nil {
+		return nil, fmt.Errorf("保存工单失败: %w", err)
+	}
+
+	log.Printf("[Session %s] 创建工单: %s", ticket.SessionID, ticket.ID)
+	return &ticket, nil
+}
+
+// GetTicket 获取工单
+func (s *ChatService) GetTicket(ctx context.Context, id string) (*model.Ticket, error) {
+	ticket, err := s.tickets.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ticket == nil {
+		return nil, dao.ErrTicketNotFound
+	}
+	return ticket, nil
+}
+
+const (
+	defaultTicketLimit = 20
+	maxTicketLimit     = 100
+)
+
+// TicketQuery 工单列表的筛选和分页条件
+type TicketQuery struct {
+	UserID string
+	Status model.TicketStatus
+	Offset int
+	Limit  int // 0 表示使用默认值，超过上限时截断
+}
+
+// ListTickets 按用户和状态分页查询工单，最新创建的在前
+func (s *ChatService) ListTickets(ctx context.Context, q TicketQuery) (*model.TicketListResponse, error) {
+	if q.Status != "" {
+		if _, ok := ticketTransitions[q.Status]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTicketStatus, q.Status)
+		}
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultTicketLimit
+	}
+	if q.Limit > maxTicketLimit {
+		q.Limit = maxTicketLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	tickets, total, err := s.tickets.List(ctx, dao.TicketFilter{UserID: q.UserID, Status: q.Status, Offset: q.Offset, Limit: q.Limit})
+	if err != nil {
+		return nil, err
+	}
+	return &model.TicketListResponse{Tickets: tickets, Total: total, Offset: q.Offset, Limit: q.Limit}, nil
+}
+
+// UpdateTicketStatus 校验并执行工单状态流转，同时记录一条备注并通知会话
+func (s *ChatService) UpdateTicketStatus(ctx context.Context, id string, req model.TicketUpdateRequest) (*model.Ticket, error) {
+	if _, ok := ticketTransitions[req.Status]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTicketStatus, req.Status)
+	}
+
+	var from model.TicketStatus
+	ticket, err := s.tickets.Update(ctx, id, func(t *model.Ticket) error {
+		from = t.Status
+		if !canTransition(t.Status, req.Status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTicketTransition, t.Status, req.Status)
+		}
+
+		note := fmt.Sprintf("状态变更: %s -> %s", t.Status, req.Status)
+		if req.Comment != "" {
+			note += "\n" + req.Comment
+		}
+		t.Status = req.Status
+		appendTicketComment(t, req.Author, note)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Ticket %s] 状态变更: %s -> %s, author=%s", id, from, ticket.Status, req.Author)
+
+	if ticket.SessionID != "" {
+		content := fmt.Sprintf("您的工单「%s」状态已更新为: %s", ticket.Subject, ticket.Status)
+		if err := s.PushToSession(ctx, ticket.SessionID, PushSourceTicket, content); err != nil {
+			log.Printf("[Ticket %s] 推送状态变更失败: %v", id, err)
+		}
+	}
+	return ticket, nil
+}
+
+// AddTicketComment 为工单添加备注
+func (s *ChatService) AddTicketComment(ctx context.Context, id, author, content string) (*model.Ticket, error) {
+	return s.tickets.Update(ctx, id, func(t *model.Ticket) error {
+		appendTicketComment(t, author, content)
+		return nil
+	})
+}
+
+// appendTicketComment 追加备注并刷新更新时间
+func appendTicketComment(t *model.Ticket, author, content string) {
+	now := time.Now().Format(time.RFC3339Nano)
+	t.Comments = append(t.Comments, model.TicketComment{
+		ID:        uuid.New().String(),
+		Author:    author,
+		Content:   content,
+		CreatedAt: now,
+	})
+	t.UpdatedAt = now
+}
+
+// ticketSubject 取第一行作为工单标题，过长时截断
+func ticketSubject(description string) string {
+	subject := strings.TrimSpace(description)
+	if i := strings.IndexByte(subject, '\n'); i >= 0 {
+		subject = strings.TrimSpace(subject[:i])
+	}
+	if runes := []rune(subject); len(runes) > ticketSubjectMaxRunes {
+		subject = string(runes[:ticketSubjectMaxRunes]) + "..."
+	}
+	return subject
+}
@@ -7,16 +7,17 @@ import (
 
 type TypeClassify struct {
 	intentDefs []model.IntentDefinition
+	threshold  float64
 }
 
-func NewTypeClassify(defs []model.IntentDefinition) *TypeClassify {
+func NewTypeClassify(config *model.IntentConfig) *TypeClassify {
 	enabled := make([]model.IntentDefinition, 0)
-	for _, d := range defs {
+	for _, d := range config.Intents {
 		if d.Enabled {
 			enabled = append(enabled, d)
 		}
 	}
-	return &TypeClassify{intentDefs: enabled}
+	return &TypeClassify{intentDefs: enabled, threshold: config.ConfidenceThreshold}
 }
 
 func (r *TypeClassify) Classify(intentID string) *model.DecisionResult {
@@ -66,3 +67,27 @@ func (r *TypeClassify) find(id string) *model.IntentDefinition {
 func (r *TypeClassify) GetIntentDef(intentID string) *model.IntentDefinition {
 	return r.find(intentID)
 }
+
+// Resolve 根据识别结果找到意图定义：优先按意图ID，其次按 flow_id
+func (r *TypeClassify) Resolve(intentID, flowID string) *model.IntentDefinition {
+	if intent := r.find(intentID); intent != nil {
+		return intent
+	}
+	if flowID == "" {
+		return nil
+	}
+	for i := range r.intentDefs {
+		if r.intentDefs[i].Type == model.IntentFlow && r.intentDefs[i].NextFlow == flowID {
+			return &r.intentDefs[i]
+		}
+	}
+	return nil
+}
+
+// Threshold 返回意图的置信度阈值，意图未配置时使用全局阈值
+func (r *TypeClassify) Threshold(intent *model.IntentDefinition) float64 {
+	if intent != nil && intent.ConfidenceThreshold > 0 {
+		return intent.ConfidenceThreshold
+	}
+	return r.threshold
+}
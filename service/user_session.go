@@ -0,0 +1,136 @@
+package service
+
+import (
+	"ai-agent/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrInvalidSessionState 会话状态筛选条件不合法
+var ErrInvalidSessionState = errors.New("invalid session state")
+
+const (
+	defaultUserSessionLimit = 20
+	maxUserSessionLimit     = 100
+)
+
+// sessionStates 可用于筛选的会话状态
+var sessionStates = map[model.SessionState]bool{
+	model.SessionNew:      true,
+	model.SessionActive:   true,
+	model.SessionOnFlow:   true,
+	model.SessionComplete: true,
+	model.SessionHandoff:  true,
+}
+
+// UserSessionQuery 用户会话列表的筛选和分页条件
+type UserSessionQuery struct {
+	State  model.SessionState
+	Offset int
+	Limit  int // 0 表示使用默认值，超过上限时截断
+}
+
+// ListUserSessions 按最近活跃时间倒序列出用户的会话，每个会话只带最后一条消息
+func (s *ChatService) ListUserSessions(ctx context.Context, userID string, q UserSessionQuery) (*model.UserSessionsResponse, error) {
+	if q.State != "" && !sessionStates[q.State] {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSessionState, q.State)
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultUserSessionLimit
+	}
+	if q.Limit > maxUserSessionLimit {
+		q.Limit = maxUserSessionLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	previews := make([]model.SessionPreview, 0)
+	var total int
+	if q.State == "" {
+		// 不按状态筛选时由存储分页，只加载当前页的会话
+		sessions, n, err := s.store.ListByUser(ctx, userID, q.Offset, q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			previews = append(previews, sessionPreview(session))
+		}
+		total = n
+	} else {
+		// 状态保存在会话文档里，筛选时需要加载全部会话
+		sessions, _, err := s.store.ListByUser(ctx, userID, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			if session.State != q.State {
+				continue
+			}
+			total++
+			if total <= q.Offset || len(previews) >= q.Limit {
+				continue
+			}
+			previews = append(previews, sessionPreview(session))
+		}
+	}
+
+	return &model.UserSessionsResponse{
+		UserID:   userID,
+		Sessions: previews,
+		Total:    total,
+		Offset:   q.Offset,
+		Limit:    q.Limit,
+	}, nil
+}
+
+// DeleteUserSessions 删除用户的全部会话，用于注销账号等场景
+func (s *ChatService) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	sessions, _, err := s.store.ListByUser(ctx, userID, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// 等待人工的会话先移出队列，避免坐席接入已删除的会话
+	for _, session := range sessions {
+		if session.State != model.SessionHandoff {
+			continue
+		}
+		if err := s.handoffQueue.Remove(ctx, session.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	deleted, err := s.store.DeleteByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	if err := s.replies.Delete(ctx, ids...); err != nil {
+		return 0, err
+	}
+	log.Printf("[User %s] 删除 %d 个会话", userID, deleted)
+	return deleted, nil
+}
+
+// sessionPreview 生成会话列表项
+func sessionPreview(session model.Session) model.SessionPreview {
+	preview := model.SessionPreview{
+		SessionID:    session.ID,
+		State:        session.State,
+		FlowID:       session.FlowID,
+		MessageCount: len(session.Messages),
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+	}
+	if n := len(session.Messages); n > 0 {
+		last := session.Messages[n-1]
+		preview.LastMessage = &last
+	}
+	return preview
+}
@@ -3,7 +3,7 @@ package utils
 // 规范化用户确认输入
 func NormalizeConfirm(input string) string {
 	input = NormalizeString(input)
-	if input == "确认" || input == "确认提交" || input == "确认退货" {
+	if input == "确认" || input == "确认提交" || input == "确认退货" || input == "确认换货" {
 		return "confirm"
 	}
 	if input == "修改" || input == "重新填写" {